@@ -0,0 +1,388 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// ACHIEVEMENTS AND QUESTS //
+
+// the job that announces each week's quest, as recorded in the store
+const weeklyQuestJob = "weekly quest"
+
+// how many turns it takes to be a seasoned adventurer
+const seasonedTurns = 100
+
+// everything we know about a player, to see what they've earned
+type playerRecord struct {
+	player   db.SlackUser
+	sessions []db.Session
+	inputs   []db.StoryItem
+}
+
+type achievement struct {
+	Name        string
+	Description string
+	earned      func(r playerRecord) bool
+}
+
+var achievements = []achievement{
+	{
+		Name:        "First Steps",
+		Description: "start your first journey",
+		earned: func(r playerRecord) bool {
+			return r.started() >= 1
+		},
+	},
+	{
+		Name:        "The End",
+		Description: "see a journey through to the end",
+		earned: func(r playerRecord) bool {
+			return r.finished("") >= 1
+		},
+	},
+	{
+		Name:        "Seasoned Adventurer",
+		Description: "play " + strconv.Itoa(seasonedTurns) + " turns",
+		earned: func(r playerRecord) bool {
+			return len(r.inputs) >= seasonedTurns
+		},
+	},
+	{
+		Name:        "Zombie Survivor",
+		Description: "survive 5 zombie apocalypses",
+		earned: func(r playerRecord) bool {
+			return r.finished("zombie") >= 5
+		},
+	},
+	{
+		Name:        "Dragon Whisperer",
+		Description: "finish 3 journeys with dragons in them",
+		earned: func(r playerRecord) bool {
+			return r.finished("dragon") >= 3
+		},
+	},
+	{
+		Name:        "Fellowship",
+		Description: "play with 10 different companions",
+		earned: func(r playerRecord) bool {
+			return len(r.companions()) >= 10
+		},
+	},
+}
+
+type quest struct {
+	Name        string
+	Description string
+	// finishing a journey that mentions this completes the quest
+	Keyword string
+}
+
+// one of these is the quest each week, in order
+var quests = []quest{
+	{"Here Be Dragons", "finish a journey with a dragon in it", "dragon"},
+	{"Night Shift", "finish a journey set in a hospital", "hospital"},
+	{"Lost in Space", "finish a journey among the stars", "space"},
+	{"A Pirate's Life", "finish a journey with pirates in it", "pirate"},
+	{"Things That Go Bump", "finish a journey with a ghost in it", "ghost"},
+	{"Heavy Is the Head", "finish a journey with a king or queen in it", "king"},
+	{"Outbreak", "finish a journey with zombies in it", "zombie"},
+}
+
+// started is how many journeys the player started themselves
+func (r playerRecord) started() int {
+	started := 0
+	for _, session := range r.sessions {
+		if session.Creator.Eq(r.player) {
+			started++
+		}
+	}
+
+	return started
+}
+
+// finished is how many journeys the player saw to the end that mention the
+// keyword, or all of them if keyword is empty
+func (r playerRecord) finished(keyword string) int {
+	return len(r.finishedSince(keyword, time.Time{}))
+}
+
+// finishedSince is the journeys the player saw to the end since the given
+// time that mention the keyword, or all of them if keyword is empty. ending a
+// journey marks it active, so that's when it ended.
+func (r playerRecord) finishedSince(keyword string, since time.Time) []db.Session {
+	// whole words only, so "king" isn't found in "looking"
+	keywordRegex := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(keyword) + `(s|es)?\b`)
+
+	finished := []db.Session{}
+	for _, session := range r.sessions {
+		if session.Status != db.StatusEnded || session.LastActiveAt.Before(since) {
+			continue
+		}
+
+		if keyword == "" || keywordRegex.MatchString(session.Prompt+" "+session.StoryMode) {
+			finished = append(finished, session)
+		}
+	}
+
+	return finished
+}
+
+// companions is everyone the player has been on a journey with
+func (r playerRecord) companions() []db.SlackUser {
+	companions := []db.SlackUser{}
+	for _, session := range r.sessions {
+		for _, participant := range session.Participants() {
+			if !participant.Eq(r.player) && !containsUser(companions, participant) {
+				companions = append(companions, participant)
+			}
+		}
+	}
+
+	return companions
+}
+
+// weekStart is midnight UTC on the monday of t's week
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
+
+// currentQuest is the quest for t's week
+func currentQuest(t time.Time) quest {
+	year, week := t.UTC().ISOWeek()
+	return quests[(year*53+week)%len(quests)]
+}
+
+// questAchievement is what completing a week's quest is recorded as, so the
+// same quest can be completed again when it comes back around
+func questAchievement(q quest, t time.Time) achievement {
+	start := weekStart(t)
+
+	return achievement{
+		Name:        "Quest: " + q.Name + " (week of " + start.Format("Jan 2, 2006") + ")",
+		Description: q.Description,
+		earned: func(r playerRecord) bool {
+			return len(r.finishedSince(q.Keyword, start)) > 0
+		},
+	}
+}
+
+// awardAchievements records anything new the player has earned. returns what
+// was just earned and everything they've earned, including that.
+func awardAchievements(dbc *db.DB, player db.SlackUser, threadTs string) ([]achievement, []db.Achievement, error) {
+	inputs, err := dbc.ListInputs(player.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessions, err := dbc.ListSessionsWithParticipant(player.ID, inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	earned, err := dbc.ListAchievements(player.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := playerRecord{player: player, sessions: sessions, inputs: inputs}
+
+	newlyEarned := []achievement{}
+	for _, a := range append(achievements, questAchievement(currentQuest(time.Now()), time.Now())) {
+		if hasAchievement(earned, a.Name) || !a.earned(record) {
+			continue
+		}
+
+		recorded, err := dbc.CreateAchievement(db.Achievement{
+			Player:          player,
+			Name:            a.Name,
+			ThreadTimestamp: threadTs,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		newlyEarned = append(newlyEarned, a)
+		earned = append(earned, recorded)
+	}
+
+	return newlyEarned, earned, nil
+}
+
+// checkAchievements announces anything new the players have earned in a
+// journey. this is a nice-to-have, so problems are only logged. it takes a
+// few trips to the store for each player, so it's only run when journeys
+// start and end, when companions are invited and when a turn reaches a
+// milestone, rather than on every turn.
+func checkAchievements(rtm *slack.RTM, thread Thread, dbc *db.DB, session db.Session, players []db.SlackUser) {
+	for _, player := range players {
+		// we start the community adventures, but don't play them
+		if player.ID == SelfID {
+			continue
+		}
+
+		newlyEarned, _, err := awardAchievements(dbc, player, session.ThreadTimestamp)
+		if err != nil {
+			log.Println("unable to check achievements for", player.ToString(), "-", err)
+			continue
+		}
+
+		for _, a := range newlyEarned {
+			threadReply(rtm, thread, ":trophy: <@"+player.ID+"> earned *"+a.Name+"* ("+a.Description+")!")
+		}
+	}
+}
+
+// checkTurnAchievements announces achievements a player earned with the turn
+// they just played. most turns can't earn one, so everything is only checked
+// when the player reaches a milestone.
+func checkTurnAchievements(rtm *slack.RTM, thread Thread, dbc *db.DB, session db.Session, player db.SlackUser) {
+	inputs, err := dbc.ListInputs(player.ID)
+	if err != nil {
+		log.Println("unable to count turns for", player.ToString(), "-", err)
+		return
+	}
+
+	if len(inputs) == seasonedTurns {
+		checkAchievements(rtm, thread, dbc, session, []db.SlackUser{player})
+	}
+}
+
+func hasAchievement(earned []db.Achievement, name string) bool {
+	for _, a := range earned {
+		if a.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// when someone wants to see what they've earned. example:
+//
+//	<@USH186XSP> achievements
+type AchievementsMsg struct {
+	AuthorID string
+	raw      *slack.MessageEvent
+}
+
+func (m AchievementsMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m AchievementsMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m AchievementsMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m AchievementsMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseAchievementsMsg(m *slack.MessageEvent) (*AchievementsMsg, bool) {
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:achievements|quests?) *$`)
+	if !regex.MatchString(m.Text) {
+		return nil, false
+	}
+
+	return &AchievementsMsg{
+		AuthorID: m.User,
+		raw:      m,
+	}, true
+}
+
+func (msg AchievementsMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	_, earned, err := awardAchievements(dbc, author, "")
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	lines := []string{}
+	if len(earned) == 0 {
+		lines = append(lines, "You haven't earned any achievements yet. Go on a journey!")
+	} else {
+		lines = append(lines, ":trophy: here's what you've earned:", "")
+		for _, a := range earned {
+			lines = append(lines, "• *"+a.Name+"*")
+		}
+	}
+
+	toEarn := []string{}
+	for _, a := range achievements {
+		if !hasAchievement(earned, a.Name) {
+			toEarn = append(toEarn, "• *"+a.Name+"*: "+a.Description)
+		}
+	}
+
+	if len(toEarn) > 0 {
+		lines = append(lines, "", "still out there:", "")
+		lines = append(lines, toEarn...)
+	}
+
+	q := currentQuest(time.Now())
+	lines = append(lines, "", "this week's quest is *"+q.Name+"*: "+q.Description+".")
+
+	threadReply(rtm, msg, strings.Join(lines, "\n"))
+}
+
+// when we last announced a weekly quest, so the store only needs checking
+// once a week
+var lastQuestAnnouncement time.Time
+
+// announceWeeklyQuest lets the community know about each week's quest
+func announceWeeklyQuest(rtm *slack.RTM, dbc *db.DB) {
+	now := time.Now()
+	if !lastQuestAnnouncement.Before(weekStart(now)) {
+		return
+	}
+
+	lastRun, err := dbc.LastJobRun(weeklyQuestJob)
+	if err != nil {
+		log.Println("unable to check when the weekly quest was last announced:", err)
+		return
+	}
+
+	if !lastRun.Before(weekStart(now)) {
+		lastQuestAnnouncement = lastRun
+		return
+	}
+
+	q := currentQuest(now)
+
+	rtm.SendMessage(rtm.NewOutgoingMessage(
+		":crossed_swords: *this week's quest: "+q.Name+"!* "+strings.ToUpper(q.Description[:1])+q.Description[1:]+" before the week is out to earn it. `@dungeon achievements` shows how you're doing.",
+		PlayDungeonChannelID,
+	))
+
+	if err := dbc.RecordJobRun(weeklyQuestJob, now); err != nil {
+		log.Println("unable to record weekly quest announcement:", err)
+	}
+
+	lastQuestAnnouncement = now
+}
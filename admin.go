@@ -0,0 +1,425 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// ADMIN //
+
+// when the bot started, for status
+var startedAt = time.Now()
+
+// everyone who's been banned, kept in memory so every message doesn't need
+// a trip to the store
+var (
+	bannedMu sync.RWMutex
+	banned   = map[string]bool{}
+)
+
+// loadBans refreshes who's banned from the store
+func loadBans(dbc *db.DB) error {
+	users, err := dbc.ListBans()
+	if err != nil {
+		return err
+	}
+
+	ids := map[string]bool{}
+	for _, user := range users {
+		ids[user.ID] = true
+	}
+
+	bannedMu.Lock()
+	defer bannedMu.Unlock()
+
+	banned = ids
+
+	return nil
+}
+
+func isBanned(userID string) bool {
+	bannedMu.RLock()
+	defer bannedMu.RUnlock()
+
+	return banned[userID]
+}
+
+func setBanned(userID string, isBanned bool) {
+	bannedMu.Lock()
+	defer bannedMu.Unlock()
+
+	if isBanned {
+		banned[userID] = true
+	} else {
+		delete(banned, userID)
+	}
+}
+
+const adminUsage = "here's what admins can do:\n\n" +
+	"• `@dungeon admin status`\n" +
+	"• `@dungeon admin end <thread>` and `@dungeon admin refund <thread>` (a thread's timestamp or a link to it)\n" +
+	"• `@dungeon admin ban @someone` and `@dungeon admin unban @someone`\n" +
+	"• `@dungeon admin set-price <GP>`\n" +
+	"• `@dungeon admin reload-config`"
+
+// when an admin needs to step in without editing the store by hand. every
+// action is recorded in the audit log. examples:
+//
+//	<@USH186XSP> admin status
+//	<@USH186XSP> admin end 1585012345.000100
+//	<@USH186XSP> admin refund <https://hackclub.slack.com/archives/CSHEL6LP5/p1585012345000100>
+//	<@USH186XSP> admin ban <@U0C7B14Q3>
+//	<@USH186XSP> admin unban <@U0C7B14Q3>
+//	<@USH186XSP> admin set-price 10
+//	<@USH186XSP> admin reload-config
+type AdminMsg struct {
+	AuthorID string
+	// "status", "end", "refund", "ban", "unban", "set-price",
+	// "reload-config" or "" if it wasn't one we know
+	Command string
+	// the thread timestamp for end and refund, the slack ID for ban and
+	// unban and the price for set-price
+	Arg string
+	raw *slack.MessageEvent
+}
+
+func (m AdminMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m AdminMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m AdminMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m AdminMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+var adminCommandRegex = regexp.MustCompile(`^(?i:(status|reload-config)|(end|refund) <?([^ |>]+)(?:\|[^>]*)?>?|(ban|unban) <@([A-Z0-9]+)(?:\|[^>]*)?>|(set-price) ([0-9]+)(?:gp)?) *$`)
+
+// ex. "1585012345.000100" or a permalink to a thread (or a reply in one)
+var (
+	threadTsRegex        = regexp.MustCompile(`^[0-9]{10}\.[0-9]{6}$`)
+	permalinkThreadRegex = regexp.MustCompile(`thread_ts=([0-9]{10}\.[0-9]{6})`)
+	permalinkRegex       = regexp.MustCompile(`/p([0-9]{10})([0-9]{6})(?:\?|$)`)
+)
+
+func ParseAdminMsg(m *slack.MessageEvent) (*AdminMsg, bool) {
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:admin)(?: (.*))?$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	msg := &AdminMsg{
+		AuthorID: m.User,
+		raw:      m,
+	}
+
+	command := adminCommandRegex.FindStringSubmatch(strings.TrimSpace(matches[1]))
+	if command == nil {
+		return msg, true
+	}
+
+	msg.Command = strings.ToLower(command[1] + command[2] + command[4] + command[6])
+	msg.Arg = command[3] + command[5] + command[7]
+
+	if msg.Command == "end" || msg.Command == "refund" {
+		threadTs, ok := parseThreadRef(msg.Arg)
+		if !ok {
+			msg.Command = ""
+		}
+
+		msg.Arg = threadTs
+	}
+
+	return msg, true
+}
+
+// parseThreadRef finds the thread timestamp in a timestamp or permalink
+func parseThreadRef(ref string) (string, bool) {
+	if threadTsRegex.MatchString(ref) {
+		return ref, true
+	}
+
+	if matches := permalinkThreadRegex.FindStringSubmatch(ref); matches != nil {
+		return matches[1], true
+	}
+
+	if matches := permalinkRegex.FindStringSubmatch(ref); matches != nil {
+		return matches[1] + "." + matches[2], true
+	}
+
+	return "", false
+}
+
+func (msg AdminMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("admin command:", msg)
+
+	if !isAdmin(msg.AuthorID) {
+		threadReply(rtm, msg, "...sorry my friend, but only admins can do that.")
+		return
+	}
+
+	admin, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	switch msg.Command {
+	case "status":
+		adminStatus(rtm, msg, dbc)
+	case "end", "refund":
+		session, err := dbc.GetSession(msg.Arg)
+		if err != nil {
+			log.Println("admin command for unknown session:", err, "-", msg)
+			threadReply(rtm, msg, "I can't find a journey in that thread.")
+			return
+		}
+
+		if msg.Command == "end" {
+			adminEnd(api, rtm, msg, dbc, aidungeonc, admin, session)
+		} else {
+			adminRefund(rtm, msg, dbc, admin, session)
+		}
+	case "ban":
+		if isAdmin(msg.Arg) {
+			threadReply(rtm, msg, "I can't ban an admin! Take them out of `ADMIN_IDS` first.")
+			return
+		}
+
+		if isBanned(msg.Arg) {
+			threadReply(rtm, msg, "<@"+msg.Arg+"> is already banned.")
+			return
+		}
+
+		user, err := db.SlackUserFromID(api, msg.Arg)
+		if err != nil {
+			handleSlackError(rtm, msg, err)
+			return
+		}
+
+		if err := dbc.CreateBan(user); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		setBanned(user.ID, true)
+		recordAdminAction(dbc, msg, admin, "ban", "", user.ToString())
+
+		threadReply(rtm, msg, "<@"+user.ID+"> is banned. I'll ignore everything they say until they're unbanned.")
+	case "unban":
+		wasBanned, err := dbc.DeleteBan(msg.Arg)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if !wasBanned {
+			threadReply(rtm, msg, "<@"+msg.Arg+"> isn't banned.")
+			return
+		}
+
+		setBanned(msg.Arg, false)
+		recordAdminAction(dbc, msg, admin, "unban", "", "<@"+msg.Arg+">")
+
+		threadReply(rtm, msg, "<@"+msg.Arg+"> is welcome back!")
+	case "set-price":
+		price, err := strconv.Atoi(msg.Arg)
+		if err != nil || price <= 0 {
+			threadReply(rtm, msg, "A journey has to cost at least 1GP!")
+			return
+		}
+
+		old := config().CostToPlay
+		updateConfig(func(c *Config) {
+			c.CostToPlay = price
+		})
+
+		recordAdminAction(dbc, msg, admin, "set price", "", strconv.Itoa(old)+"GP to "+strconv.Itoa(price)+"GP")
+
+		threadReply(rtm, msg, "New journeys now cost "+strconv.Itoa(price)+"GP (up from "+strconv.Itoa(old)+"GP). Set `COST_TO_PLAY` to keep it that way after a restart or reload.")
+	case "reload-config":
+		if err := godotenv.Overload(); err != nil {
+			log.Println("unable to reload .env:", err)
+		}
+
+		setConfig(loadConfig())
+
+		if err := loadModerator(); err != nil {
+			log.Println("unable to reload moderation word list:", err)
+			threadReply(rtm, msg, "I couldn't read the moderation word list, so I'm sticking with the old one. (check the logs)")
+		}
+
+		if err := loadBans(dbc); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if err := loadScenarioNames(dbc); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		recordAdminAction(dbc, msg, admin, "reload config", "", "")
+
+		threadReply(rtm, msg, "Config reloaded! New journeys cost "+strconv.Itoa(config().CostToPlay)+"GP.")
+	default:
+		threadReply(rtm, msg, adminUsage)
+	}
+}
+
+func adminStatus(rtm *slack.RTM, msg AdminMsg, dbc *db.DB) {
+	active, err := dbc.ListSessionsActiveSince(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	open, err := dbc.ListOpenSessions()
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	bannedMu.RLock()
+	bans := len(banned)
+	bannedMu.RUnlock()
+
+	c := config()
+
+	lines := []string{
+		"*status*",
+		"",
+		"• up for " + time.Since(startedAt).Round(time.Minute).String(),
+		"• " + plural(len(active), "journey") + " played in the last day, " + strconv.Itoa(len(open)) + " open to everyone",
+		"• journeys cost " + strconv.Itoa(c.CostToPlay) + "GP",
+		"• " + plural(len(c.AdminIDs), "admin") + ", " + plural(bans, "banned user"),
+		"• rate limits are " + c.UserRateLimit.String() + " per player, " + c.ChannelRateLimit.String() + " per channel and " + c.GlobalRateLimit.String() + " overall, with " + dailyQuota(c.DailyTurnQuota),
+		"• moderation is " + c.ModerationStrictness + " (" + plural(len(c.ModerationChannels), "channel") + " set differently)",
+	}
+
+	if c.DailyAdventureTime != "" {
+		lines = append(lines, "• daily adventures go up at "+c.DailyAdventureTime+" UTC")
+	}
+
+	if c.DigestChannelID != "" {
+		lines = append(lines, "• weekly digests go to <#"+c.DigestChannelID+">")
+	}
+
+	threadReply(rtm, msg, strings.Join(lines, "\n"))
+}
+
+// ex. "100 turns a day each"
+func dailyQuota(quota int) string {
+	if quota == 0 {
+		return "no daily turn quota"
+	}
+
+	return plural(quota, "turn") + " a day each"
+}
+
+func adminEnd(api *slack.Client, rtm *slack.RTM, msg AdminMsg, dbc *db.DB, aidungeonc aidungeon.Client, admin db.SlackUser, session db.Session) {
+	if session.Status == db.StatusEnded {
+		threadReply(rtm, msg, "That journey is already over.")
+		return
+	}
+
+	if !session.Paid {
+		threadReply(rtm, msg, "That journey never started, so there's nothing to end.")
+		return
+	}
+
+	recordAdminAction(dbc, msg, admin, "end", session.ThreadTimestamp, "")
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &admin, "ended the journey as an admin"); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	endJourney(api, rtm, sessionThread{session}, dbc, aidungeonc, session)
+
+	threadReply(rtm, msg, "Done! That journey has come to an end.")
+}
+
+func adminRefund(rtm *slack.RTM, msg AdminMsg, dbc *db.DB, admin db.SlackUser, session db.Session) {
+	if !session.Paid {
+		threadReply(rtm, msg, "Nobody paid for that journey, so there's nothing to refund.")
+		return
+	}
+
+	if session.Refunded {
+		threadReply(rtm, msg, "That journey was already refunded.")
+		return
+	}
+
+	if session.Creator.ID == SelfID {
+		threadReply(rtm, msg, "The house paid for that journey, so there's nobody to refund.")
+		return
+	}
+
+	session, err := dbc.MarkSessionRefunded(session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	recordAdminAction(dbc, msg, admin, "refund", session.ThreadTimestamp, strconv.Itoa(session.CostGP)+"GP to "+session.Creator.ToString())
+
+	thread := sessionThread{session}
+
+	// the banker handles the actual transfer
+	threadReply(rtm, thread, "<@"+BankerID+"> give <@"+session.Creator.ID+"> "+strconv.Itoa(session.CostGP)+" for refund")
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &admin, "refunded "+strconv.Itoa(session.CostGP)+"GP"); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	// a refunded journey can't keep going
+	if session.Status != db.StatusEnded {
+		if _, err := dbc.SetSessionStatus(session, db.StatusEnded); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		threadReply(rtm, thread, "_this journey has been refunded and brought to an end._")
+	}
+
+	threadReply(rtm, msg, "Refunded "+strconv.Itoa(session.CostGP)+"GP to <@"+session.Creator.ID+">.")
+}
+
+// recordAdminAction adds an admin action to the audit log. threadTs is the
+// journey it was taken on, if any.
+func recordAdminAction(dbc *db.DB, msg AdminMsg, admin db.SlackUser, action, threadTs, details string) {
+	_, err := dbc.CreateAuditRecord(db.AuditRecord{
+		Action:          "admin " + action,
+		Actor:           &admin,
+		ChannelID:       msg.ChannelID(),
+		ThreadTimestamp: threadTs,
+		Details:         details,
+	})
+	if err != nil {
+		log.Println("unable to record admin action", action, "-", err)
+	}
+}
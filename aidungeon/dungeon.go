@@ -55,15 +55,58 @@ func NewClient(email, password string) (Client, error) {
 	}, nil
 }
 
+// Preset story modes and the character types each can be played as. The
+// first character type is the default.
+var StoryModes = map[string][]string{
+	"fantasy":     {"knight", "noble", "squire", "wizard", "ranger", "peasant", "rogue"},
+	"mystery":     {"detective", "patient", "spy"},
+	"apocalyptic": {"survivor", "soldier", "scavenger", "courier"},
+	"zombies":     {"survivor", "soldier", "scientist"},
+}
+
+// StoryModeCustom is the story mode for sessions started from a prompt
+const StoryModeCustom = "custom"
+
+// ValidPreset reports whether the character type can be played in the story
+// mode
+func ValidPreset(storyMode, characterType string) bool {
+	for _, t := range StoryModes[storyMode] {
+		if t == characterType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateSession starts a session from a custom prompt
 func (c Client) CreateSession(prompt string) (sessionId int, output string, err error) {
-	body := map[string]interface{}{
-		"storyMode":     "custom",
+	return c.createSession(map[string]interface{}{
+		"storyMode":     StoryModeCustom,
 		"characterType": nil,
 		"name":          nil,
 		"customPrompt":  &prompt,
 		"promptId":      nil,
+	})
+}
+
+// CreatePresetSession starts a session in one of AI Dungeon's preset story
+// modes, as a character of the given type and name
+func (c Client) CreatePresetSession(storyMode, characterType, name string) (sessionId int, output string, err error) {
+	if !ValidPreset(storyMode, characterType) {
+		return 0, "", errors.New("unknown story mode or character type: " + storyMode + " " + characterType)
 	}
 
+	return c.createSession(map[string]interface{}{
+		"storyMode":     storyMode,
+		"characterType": &characterType,
+		"name":          &name,
+		"customPrompt":  nil,
+		"promptId":      nil,
+	})
+}
+
+func (c Client) createSession(body map[string]interface{}) (sessionId int, output string, err error) {
 	reqBody, err := json.Marshal(body)
 	if err != nil {
 		return 0, "", err
@@ -108,53 +151,91 @@ func (c Client) CreateSession(prompt string) (sessionId int, output string, err
 }
 
 func (c Client) Input(sessionId int, text string) (output string, err error) {
+	story, err := c.sendInput(sessionId, text)
+	if err != nil {
+		return "", err
+	}
+
+	last := story[len(story)-1]
+	if last.Type == "input" {
+		return "", errors.New("last type is input instead of output...")
+	}
+
+	return last.Value, nil
+}
+
+// The story editing commands below are sent as special inputs, the same way
+// AI Dungeon's own clients send them.
+
+// Undo reverts the session's last input and the output generated for it
+func (c Client) Undo(sessionId int) error {
+	_, err := c.sendInput(sessionId, "/revert")
+	return err
+}
+
+// Retry throws away the session's last output and generates a new one
+func (c Client) Retry(sessionId int) (output string, err error) {
+	return c.Input(sessionId, "/retry")
+}
+
+// Alter replaces the session's last output with the given text
+func (c Client) Alter(sessionId int, text string) error {
+	_, err := c.sendInput(sessionId, "/alter "+text)
+	return err
+}
+
+// Remember pins text to the session's context so the engine keeps it in mind
+// however long the story gets. it replaces whatever was pinned before, so
+// pinning nothing clears it.
+func (c Client) Remember(sessionId int, memory string) error {
+	_, err := c.sendInput(sessionId, "/remember "+memory)
+	return err
+}
+
+type storyItem struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sendInput posts text to the session and returns its story so far
+func (c Client) sendInput(sessionId int, text string) ([]storyItem, error) {
 	body := map[string]string{
 		"text": text,
 	}
 
 	reqBody, err := json.Marshal(body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	client := &http.Client{}
 
 	req, err := http.NewRequest("POST", "https://api.aidungeon.io/sessions/"+strconv.Itoa(sessionId)+"/inputs", bytes.NewBuffer(reqBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("x-access-token", c.AuthToken)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		io.Copy(os.Stdout, resp.Body)
-		return "", errors.New(fmt.Sprint("http error, status code ", resp.StatusCode))
+		return nil, errors.New(fmt.Sprint("http error, status code ", resp.StatusCode))
 	}
 
-	type InputResp []struct {
-		Type  string `json:"type"`
-		Value string `json:"value"`
-	}
-
-	var inputResp InputResp
+	var inputResp []storyItem
 	decoder := json.NewDecoder(resp.Body)
 	if err := decoder.Decode(&inputResp); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(inputResp) == 0 {
-		return "", errors.New("resp length is zero for some reason...")
+		return nil, errors.New("resp length is zero for some reason...")
 	}
 
-	last := inputResp[len(inputResp)-1]
-	if last.Type == "input" {
-		return "", errors.New("last type is input instead of output...")
-	}
-
-	return last.Value, nil
+	return inputResp, nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// CHARACTER NAMES //
+
+// when a participant tells us who they're playing as. the name must be
+// capitalized so everyday inputs like "I am hungry" still go to the story.
+// examples:
+//
+//	<@USH186XSP> I am Sir Bob
+//	<@USH186XSP> I'm Ada Lovelace
+type CharacterMsg struct {
+	AuthorID string
+	Name     string
+	raw      *slack.MessageEvent
+}
+
+func (m CharacterMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m CharacterMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m CharacterMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m CharacterMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseCharacterMsg(m *slack.MessageEvent) (*CharacterMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	// names can't have commas since they're stored in a comma separated
+	// list
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?:[Ii] am|I'm|I’m) ([A-Z][^.!?,<>]{0,39}?)[.!]? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &CharacterMsg{
+		AuthorID: m.User,
+		Name:     strings.TrimSpace(matches[1]),
+		raw:      m,
+	}, true
+}
+
+func (msg CharacterMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("character name registered:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("character name given, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.IsParticipant(author) {
+		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to embark on.")
+		return
+	}
+
+	if !allowInput(rtm, msg, dbc, author, msg.Name) {
+		return
+	}
+
+	session, err = dbc.SetSessionCharacter(session, author, msg.Name)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &author, "is playing as "+msg.Name); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	threadReply(rtm, msg, "Well met, *"+msg.Name+"*! I'll tell the story of everything you do from here on.")
+}
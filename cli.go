@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/nlopes/slack"
+
+	"./db"
+	"./transcript"
+)
+
+// COMMAND LINE //
+
+const usage = `usage: dungeon [command]
+
+with no command, runs the bot. commands:
+
+  export [-format markdown|html|text] <thread timestamp>
+        write a journey's transcript to stdout
+
+  publish [-out dir]
+        generate a static storybook site of every finished journey
+`
+
+// runCommand runs one of our command line tools instead of the bot
+func runCommand(args []string) {
+	switch args[0] {
+	case "export":
+		exportCommand(args[1:])
+	case "publish":
+		publishCommand(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", transcript.Markdown, "markdown, html or text")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	dbc, err := db.NewDB(os.Getenv("AIRTABLE_API_KEY"), os.Getenv("AIRTABLE_BASE"))
+	if err != nil {
+		log.Fatal("error connecting with airtable:", err)
+	}
+
+	session, err := dbc.GetSession(flags.Arg(0))
+	if err != nil {
+		log.Fatal("unable to find session:", err)
+	}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		log.Fatal("unable to get story items:", err)
+	}
+
+	if err := transcript.New(session, items).Render(os.Stdout, parseTranscriptFormat(*format)); err != nil {
+		log.Fatal("unable to render transcript:", err)
+	}
+}
+
+func publishCommand(args []string) {
+	flags := flag.NewFlagSet("publish", flag.ExitOnError)
+	out := flags.String("out", "site", "directory to generate the site in")
+	flags.Parse(args)
+
+	dbc, err := db.NewDB(os.Getenv("AIRTABLE_API_KEY"), os.Getenv("AIRTABLE_BASE"))
+	if err != nil {
+		log.Fatal("error connecting with airtable:", err)
+	}
+
+	api := slack.New(os.Getenv("SLACK_LEGACY_TOKEN"))
+
+	sessions, err := dbc.ListPublishableSessions()
+	if err != nil {
+		log.Fatal("unable to list sessions:", err)
+	}
+
+	journeys := []transcript.Transcript{}
+	for _, session := range sessions {
+		items, err := dbc.GetStoryItems(session)
+		if err != nil {
+			log.Fatal("unable to get story items:", err)
+		}
+
+		journey := transcript.New(session, items)
+		if len(journey.Turns) == 0 {
+			log.Println("skipping journey with no story:", session.ThreadTimestamp)
+			continue
+		}
+
+		// sessions from before we tracked channels can't be linked to
+		if session.ChannelID != "" {
+			journey.Link, err = api.GetPermalink(&slack.PermalinkParameters{
+				Channel: session.ChannelID,
+				Ts:      session.ThreadTimestamp,
+			})
+			if err != nil {
+				log.Println("unable to get permalink for", session.ThreadTimestamp, "-", err)
+			}
+		}
+
+		journeys = append(journeys, journey)
+	}
+
+	if err := transcript.WriteSite(*out, journeys); err != nil {
+		log.Fatal("unable to write site:", err)
+	}
+
+	log.Println("published", len(journeys), "journeys to", *out)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// COMPANION MANAGEMENT //
+
+// when users change who's on a journey from inside its thread. examples:
+//
+//	<@USH186XSP> invite <@U0C7B14Q3> <@UDYDSUDHV>
+//	<@USH186XSP> kick <@U0C7B14Q3>
+//	<@USH186XSP> transfer <@UDYDSUDHV>
+//	<@USH186XSP> leave
+type CompanionMsg struct {
+	AuthorID  string
+	Command   string
+	TargetIDs []string
+	raw       *slack.MessageEvent
+}
+
+func (m CompanionMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m CompanionMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m CompanionMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m CompanionMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseCompanionMsg(m *slack.MessageEvent) (*CompanionMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	// 2 parts of message: 1. the command and 2. the users it applies to.
+	// anything else after the command is treated as regular story input
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (invite|kick|transfer|leave)((?: *<@[A-Z0-9]+>)*) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	command := matches[1]
+	targetText := matches[2]
+
+	slackUserIDRegex := regexp.MustCompile(`<@([A-Z0-9]+)>`)
+	rawTargetIDResults := slackUserIDRegex.FindAllStringSubmatch(targetText, -1)
+
+	targetIDs := make([]string, len(rawTargetIDResults))
+	for i, targetIDResult := range rawTargetIDResults {
+		targetIDs[i] = targetIDResult[1]
+	}
+
+	switch command {
+	case "leave":
+		if len(targetIDs) != 0 {
+			return nil, false
+		}
+	case "transfer":
+		if len(targetIDs) != 1 {
+			return nil, false
+		}
+	default:
+		if len(targetIDs) == 0 {
+			return nil, false
+		}
+	}
+
+	return &CompanionMsg{
+		AuthorID:  m.User,
+		Command:   command,
+		TargetIDs: targetIDs,
+		raw:       m,
+	}, true
+}
+
+func (msg CompanionMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("companion command received:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("companion command attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	targets, err := db.SlackUsersFromIDs(api, msg.TargetIDs)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	isCreator := session.Creator.Eq(author)
+
+	creator := session.Creator
+	companions := session.Companions
+	var note, reply string
+
+	switch msg.Command {
+	case "invite":
+		if !isCreator {
+			threadReply(rtm, msg, "Only "+session.Creator.Name+" can invite new companions on this journey.")
+			return
+		}
+
+		var invited []db.SlackUser
+		for _, target := range targets {
+			// anyone can play open journeys, but only those listed are
+			// on them
+			if target.ID == SelfID || containsUser(session.Participants(), target) {
+				continue
+			}
+
+			invited = append(invited, target)
+		}
+
+		if len(invited) == 0 {
+			threadReply(rtm, msg, "Everyone you mentioned is already on this journey!")
+			return
+		}
+
+		companions = append(append([]db.SlackUser{}, companions...), invited...)
+		note = "invited " + db.SlackUsersToString(invited)
+		reply = "Welcome aboard, " + mentions(invited) + "! @mention me to join in on the journey."
+	case "kick":
+		if !isCreator {
+			threadReply(rtm, msg, "Only "+session.Creator.Name+" can remove companions from this journey.")
+			return
+		}
+
+		var kicked []db.SlackUser
+		companions = nil
+		for _, companion := range session.Companions {
+			if containsUser(targets, companion) {
+				kicked = append(kicked, companion)
+				continue
+			}
+
+			companions = append(companions, companion)
+		}
+
+		if len(kicked) == 0 {
+			threadReply(rtm, msg, "I can only remove companions who are on this journey (and never its creator).")
+			return
+		}
+
+		note = "removed " + db.SlackUsersToString(kicked)
+		reply = "Farewell, " + mentions(kicked) + ". The journey continues without you."
+	case "leave":
+		if isCreator {
+			threadReply(rtm, msg, "You can't abandon your own journey! Hand it off first with `@dungeon transfer @someone`.")
+			return
+		}
+
+		if !containsUser(session.Companions, author) {
+			threadReply(rtm, msg, "...you can't leave a journey you were never on, my friend.")
+			return
+		}
+
+		companions = nil
+		for _, companion := range session.Companions {
+			if !companion.Eq(author) {
+				companions = append(companions, companion)
+			}
+		}
+
+		note = "left the journey"
+		reply = "Safe travels, <@" + author.ID + ">."
+	case "transfer":
+		if !isCreator {
+			threadReply(rtm, msg, "Only "+session.Creator.Name+" can hand off this journey.")
+			return
+		}
+
+		newCreator := targets[0]
+		if newCreator.ID == SelfID || newCreator.Eq(session.Creator) {
+			threadReply(rtm, msg, "...that wouldn't change a thing.")
+			return
+		}
+
+		// the old creator sticks around as a companion
+		creator = newCreator
+		companions = []db.SlackUser{session.Creator}
+		for _, companion := range session.Companions {
+			if !companion.Eq(newCreator) {
+				companions = append(companions, companion)
+			}
+		}
+
+		note = "transferred the journey to " + newCreator.ToString()
+		reply = "This journey now belongs to <@" + newCreator.ID + ">. Lead us well."
+	}
+
+	session, err = dbc.UpdateSessionParticipants(session, creator, companions)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	session, err = syncTurnOrder(dbc, session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &author, note); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	threadReply(rtm, msg, reply)
+
+	// new companions count towards everyone's fellowship
+	if msg.Command == "invite" {
+		checkAchievements(rtm, msg, dbc, session, session.Participants())
+	}
+}
+
+func containsUser(users []db.SlackUser, user db.SlackUser) bool {
+	for _, u := range users {
+		if u.Eq(user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formats users as slack @mentions, ex. "<@U0C7B14Q3> and <@UDYDSUDHV>"
+func mentions(users []db.SlackUser) string {
+	str := ""
+	for i, u := range users {
+		if i > 0 && i == len(users)-1 {
+			str += " and "
+		} else if i > 0 {
+			str += ", "
+		}
+
+		str += "<@" + u.ID + ">"
+	}
+
+	return str
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"./ratelimit"
+)
+
+// CONFIG //
+
+// Settings operators can tune through the environment (or .env) without
+// touching the code. Unset or invalid values fall back to sane defaults.
+// Admins can reload them while the bot runs.
+type Config struct {
+	// slack IDs of the people who can manage the bot, ex. world lore
+	AdminIDs []string
+
+	// what a new journey costs, in GP
+	CostToPlay int
+
+	// how long a player in a turn-taking journey has to act before they're
+	// skipped
+	TurnTimeout time.Duration
+
+	// how long players in a voting journey can propose actions
+	VoteProposalWindow time.Duration
+	// how long the ballot stays open once it's posted
+	VoteWindow time.Duration
+	// the fewest voters needed for a round to count
+	VoteMinTurnout int
+	// how tied votes are settled: "first" (earliest proposal wins),
+	// "random" or "none" (nobody wins and the round is thrown out)
+	VoteTieBreak string
+
+	// journeys nobody plays for this many days are paused. 0 never pauses
+	// them.
+	AutoPauseDays int
+
+	// how recaps are written: "extractive" picks out key sentences locally,
+	// "engine" has AI Dungeon summarize the story (falling back to
+	// extractive if it can't)
+	RecapMode string
+
+	// whether character sheets are pinned to the engine's context along
+	// with memories
+	SheetsInContext bool
+
+	// what a d20 roll plus stat modifier needs to reach for a skill check
+	// to succeed
+	CheckDifficulty int
+
+	// when the daily community adventure is posted, as "15:04" in UTC.
+	// empty turns it off.
+	DailyAdventureTime string
+	// where daily adventures come from: "generator" or "scenarios" (the
+	// scenario library, falling back to the generator)
+	DailyAdventureSource string
+	// how much GP a month the house spends on daily adventures
+	HouseBudgetGP int
+
+	// where the weekly digest of the best journeys is posted. empty turns
+	// it off.
+	DigestChannelID string
+
+	// how closely channels are moderated: "lenient", "strict" or "off"
+	ModerationStrictness string
+	// channels moderated differently from the rest, by channel ID
+	ModerationChannels map[string]string
+	// the file of blocked words and patterns
+	ModerationList string
+
+	// how often each player, each channel and everyone together can play
+	// turns and start journeys
+	UserRateLimit    ratelimit.Rate
+	ChannelRateLimit ratelimit.Rate
+	GlobalRateLimit  ratelimit.Rate
+	// how many turns a player can take each day (UTC). 0 is unlimited.
+	DailyTurnQuota int
+}
+
+var (
+	configMu      sync.RWMutex
+	currentConfig Config
+)
+
+// config returns the settings in effect right now. they can change between
+// calls when an admin reloads them.
+func config() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	return currentConfig
+}
+
+func setConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	currentConfig = c
+}
+
+// updateConfig changes settings in place, ex. when an admin sets the price
+func updateConfig(update func(*Config)) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	update(&currentConfig)
+}
+
+func loadConfig() Config {
+	costToPlay := intEnv("COST_TO_PLAY", 5)
+
+	return Config{
+		AdminIDs:           listEnv("ADMIN_IDS"),
+		CostToPlay:         costToPlay,
+		TurnTimeout:        durationEnv("TURN_TIMEOUT", 10*time.Minute),
+		VoteProposalWindow: durationEnv("VOTE_PROPOSAL_WINDOW", 3*time.Minute),
+		VoteWindow:         durationEnv("VOTE_WINDOW", 3*time.Minute),
+		VoteMinTurnout:     intEnv("VOTE_MIN_TURNOUT", 1),
+		VoteTieBreak:       choiceEnv("VOTE_TIE_BREAK", "first", "random", "none"),
+		AutoPauseDays:      intEnv("AUTO_PAUSE_DAYS", 7),
+		RecapMode:          choiceEnv("RECAP_MODE", "extractive", "engine"),
+		SheetsInContext:    boolEnv("SHEETS_IN_CONTEXT", false),
+		CheckDifficulty:    intEnv("CHECK_DIFFICULTY", 12),
+
+		DailyAdventureTime:   clockEnv("DAILY_ADVENTURE_TIME"),
+		DailyAdventureSource: choiceEnv("DAILY_ADVENTURE_SOURCE", "generator", "scenarios"),
+		HouseBudgetGP:        intEnv("HOUSE_BUDGET_GP", 31*costToPlay),
+
+		DigestChannelID: os.Getenv("DIGEST_CHANNEL_ID"),
+
+		ModerationStrictness: choiceEnv("MODERATION_STRICTNESS", "lenient", "strict", "off"),
+		ModerationChannels:   mapEnv("MODERATION_CHANNELS"),
+		ModerationList:       stringEnv("MODERATION_LIST", "moderation.txt"),
+
+		UserRateLimit:    rateEnv("USER_RATE_LIMIT", ratelimit.Rate{Count: 5, Per: time.Minute}),
+		ChannelRateLimit: rateEnv("CHANNEL_RATE_LIMIT", ratelimit.Rate{Count: 20, Per: time.Minute}),
+		GlobalRateLimit:  rateEnv("GLOBAL_RATE_LIMIT", ratelimit.Rate{Count: 60, Per: time.Minute}),
+		DailyTurnQuota:   intEnv("DAILY_TURN_QUOTA", 100),
+	}
+}
+
+// isAdmin reports whether a slack user can manage the bot
+func isAdmin(userID string) bool {
+	for _, id := range config().AdminIDs {
+		if id == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ex. "U0C7B14Q3,UDYDSUDHV"
+func listEnv(key string) []string {
+	list := []string{}
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// ex. "CSHEL6LP5:strict,C0C7B14Q3:off"
+func mapEnv(key string) map[string]string {
+	m := map[string]string{}
+	for _, item := range listEnv(key) {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			log.Println("invalid entry for", key, "- ignoring", item)
+			continue
+		}
+
+		m[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return m
+}
+
+func stringEnv(key, fallback string) string {
+	if raw := os.Getenv(key); raw != "" {
+		return raw
+	}
+
+	return fallback
+}
+
+// ex. "90s", "15m", "1h30m"
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		log.Println("invalid duration for", key, "- using default of", fallback)
+		return fallback
+	}
+
+	return d
+}
+
+// ex. "5/1m", "100/24h" or "off"
+func rateEnv(key string, fallback ratelimit.Rate) ratelimit.Rate {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	rate, err := ratelimit.ParseRate(raw)
+	if err != nil {
+		log.Println("invalid rate for", key, "- using default of", fallback)
+		return fallback
+	}
+
+	return rate
+}
+
+func intEnv(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(raw)
+	if err != nil || i < 0 {
+		log.Println("invalid number for", key, "- using default of", fallback)
+		return fallback
+	}
+
+	return i
+}
+
+// ex. "true", "1", "false"
+func boolEnv(key string, fallback bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Println("invalid true/false for", key, "- using default of", fallback)
+		return fallback
+	}
+
+	return b
+}
+
+// a time of day, ex. "09:30". empty if unset or invalid.
+func clockEnv(key string) string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return ""
+	}
+
+	if _, err := time.Parse("15:04", raw); err != nil {
+		log.Println("invalid time of day for", key, "- leaving it off")
+		return ""
+	}
+
+	return raw
+}
+
+// the first choice is the default
+func choiceEnv(key string, choices ...string) string {
+	raw := strings.ToLower(os.Getenv(key))
+	if raw == "" {
+		return choices[0]
+	}
+
+	for _, choice := range choices {
+		if raw == choice {
+			return choice
+		}
+	}
+
+	log.Println("invalid choice for", key, "- using default of", choices[0])
+	return choices[0]
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./story"
+)
+
+// DAILY COMMUNITY ADVENTURES //
+
+// the job that posts each day's adventure, as recorded in the store
+const dailyAdventureJob = "daily adventure"
+
+// daily adventures are started (and paid for) by the house, which is us
+var house = db.SlackUser{ID: SelfID, Name: "dungeon"}
+
+// when we last posted a daily adventure, so the store only needs checking
+// once a day
+var lastDailyAdventure time.Time
+
+// runDailyAdventure closes yesterday's community adventure and posts today's
+// once it's time
+func runDailyAdventure(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	if config().DailyAdventureTime == "" {
+		return
+	}
+
+	now := time.Now()
+	scheduled := dailyAdventureTime(now)
+	if now.Before(scheduled) || !lastDailyAdventure.Before(scheduled) {
+		return
+	}
+
+	lastRun, err := dbc.LastJobRun(dailyAdventureJob)
+	if err != nil {
+		log.Println("unable to check when the daily adventure was last posted:", err)
+		return
+	}
+
+	if !lastRun.Before(scheduled) {
+		lastDailyAdventure = lastRun
+		return
+	}
+
+	// recorded up front so a problem posting doesn't have us trying again
+	// every minute
+	if err := dbc.RecordJobRun(dailyAdventureJob, now); err != nil {
+		log.Println("unable to record daily adventure:", err)
+		return
+	}
+	lastDailyAdventure = now
+
+	closeDailyAdventures(api, rtm, dbc, aidungeonc)
+	postDailyAdventure(api, rtm, dbc, aidungeonc, now)
+}
+
+// dailyAdventureTime is when the adventure is posted on t's day
+func dailyAdventureTime(t time.Time) time.Time {
+	clock, _ := time.Parse("15:04", config().DailyAdventureTime)
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), t.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+}
+
+// closeDailyAdventures ends every community adventure still going, crediting
+// everyone who played
+func closeDailyAdventures(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	sessions, err := dbc.ListOpenSessions()
+	if err != nil {
+		log.Println("unable to list open sessions to close:", err)
+		return
+	}
+
+	for _, session := range sessions {
+		log.Println("closing daily adventure", session.ThreadTimestamp)
+
+		thread := sessionThread{session}
+
+		items, err := dbc.GetStoryItems(session)
+		if err != nil {
+			log.Println("unable to get daily adventure's story items:", err)
+			continue
+		}
+
+		players := []db.SlackUser{}
+		for _, item := range items {
+			if item.Type == "Input" && item.Author != nil && !containsUser(players, *item.Author) {
+				players = append(players, *item.Author)
+			}
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", nil, "closed at the end of the day"); err != nil {
+			log.Println("unable to record daily adventure closing:", err)
+		}
+
+		threadReply(rtm, thread, "_the sun sets on today's adventure..._")
+
+		endJourney(api, rtm, thread, dbc, aidungeonc, session)
+
+		if len(players) == 0 {
+			threadReply(rtm, thread, "Nobody joined in this time. Maybe tomorrow!")
+			continue
+		}
+
+		threadReply(rtm, thread, ":raised_hands: thank you to everyone who played: "+mentions(players)+"!")
+
+		checkAchievements(rtm, thread, dbc, session, players)
+	}
+}
+
+// postDailyAdventure starts a new community adventure anyone can play, paid
+// for from the house budget
+func postDailyAdventure(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client, now time.Time) {
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	spent, err := dbc.SpentOnOpenSessions(monthStart)
+	if err != nil {
+		log.Println("unable to check the house budget:", err)
+		return
+	}
+
+	if spent+config().CostToPlay > config().HouseBudgetGP {
+		log.Println("house budget spent for the month, skipping daily adventure. spent", spent, "of", config().HouseBudgetGP)
+		return
+	}
+
+	// one seed per day, so each day's adventure can be made again
+	seed := now.Unix() / int64(24*time.Hour/time.Second)
+	prompt, scenarioAirtableID := dailyPrompt(dbc, seed)
+
+	_, ts, err := api.PostMessage(
+		PlayDungeonChannelID,
+		slack.MsgOptionText(":sunrise: *today's community adventure!* anyone can play, just @mention me in this thread with what happens next.\n\n_"+prompt+"_", false),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		log.Println("unable to post daily adventure:", err)
+		return
+	}
+
+	session, err := dbc.CreateSession(db.Session{
+		ThreadTimestamp:    ts,
+		ChannelID:          PlayDungeonChannelID,
+		Creator:            house,
+		CostGP:             config().CostToPlay,
+		Prompt:             prompt,
+		ScenarioAirtableID: scenarioAirtableID,
+		Open:               true,
+	})
+	if err != nil {
+		log.Println("unable to create daily adventure session:", err)
+		return
+	}
+
+	log.Println("DAILY ADVENTURE CREATED", session)
+
+	if err := dbc.CreateStoryItem(session, "Metadata", nil, "paid "+strconv.Itoa(config().CostToPlay)+"GP from the house budget"); err != nil {
+		log.Println("unable to record daily adventure payment:", err)
+	}
+
+	thread := sessionThread{session}
+
+	typing(rtm, thread)
+
+	if _, ok := startStory(api, rtm, thread, dbc, aidungeonc, session); !ok {
+		return
+	}
+
+	threadReply(rtm, thread, "_(this adventure wraps up tomorrow at "+config().DailyAdventureTime+" UTC, when the next one starts.)_")
+}
+
+// dailyPrompt picks the day's prompt, and the scenario it came from if any
+func dailyPrompt(dbc *db.DB, seed int64) (prompt, scenarioAirtableID string) {
+	if config().DailyAdventureSource == "scenarios" {
+		scenarios, err := dbc.ListScenarios("")
+		if err != nil {
+			log.Println("unable to list scenarios for the daily adventure, generating one instead:", err)
+		}
+
+		if len(scenarios) > 0 {
+			scenario := scenarios[rand.New(rand.NewSource(seed)).Intn(len(scenarios))]
+
+			prompt, missing := fillScenario(scenario.Prompt, map[string]string{
+				"name": story.GenerateName(seed),
+			})
+			if len(missing) == 0 {
+				return prompt, scenario.AirtableID
+			}
+
+			log.Println("daily adventure scenario", scenario.Name, "needs", strings.Join(missing, ", "), "- generating one instead")
+		}
+	}
+
+	return story.Generate(seed), ""
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fabioberger/airtable-go"
 	"github.com/nlopes/slack"
@@ -100,7 +103,9 @@ func SlackUsersFromString(str string) ([]SlackUser, error) {
 	slackUsers := make([]SlackUser, len(matches))
 	for i, match := range matches {
 		slackUsers[i] = SlackUser{
-			Name: match[2],
+			// every match after the first starts with the ", " the list
+			// was joined with
+			Name: strings.TrimPrefix(match[2], ", "),
 			ID:   match[3],
 		}
 	}
@@ -108,27 +113,122 @@ func SlackUsersFromString(str string) ([]SlackUser, error) {
 	return slackUsers, nil
 }
 
+// How inputs from a session's participants are accepted
+const (
+	// anyone on the journey can input at any time
+	PlayModeFree = "Free"
+	// participants take turns in round-robin order
+	PlayModeTurns = "Turns"
+	// participants propose actions and everyone votes on the next one
+	PlayModeVote = "Vote"
+)
+
+// Where a session is in its life
+const (
+	// being played
+	StatusActive = "Active"
+	// on hold until someone resumes it
+	StatusPaused = "Paused"
+	// finished for good
+	StatusEnded = "Ended"
+)
+
+// Where a voting session's current round is at
+const (
+	// no round is running, the next proposal starts one
+	VotePhaseNone = ""
+	// participants are proposing actions
+	VotePhaseProposing = "Proposing"
+	// the ballot is posted and reactions are being counted as votes
+	VotePhaseVoting = "Voting"
+)
+
 type Session struct {
 	AirtableID      string
 	ThreadTimestamp string
+	ChannelID       string
 	Creator         SlackUser
 	Companions      []SlackUser
 	CostGP          int
 	Paid            bool
 	Prompt          string
 	SessionID       int
+	PlayMode        string
+	TurnOrder       []SlackUser
+	CurrentTurn     int
+	TurnStartedAt   time.Time
+	VotePhase       string
+	VoteStartedAt   time.Time
+	BallotTimestamp string
+	// participants who've registered a character name, with Name set to
+	// that character's name rather than their Slack name
+	Characters   []SlackUser
+	Status       string
+	LastActiveAt time.Time
+	// for forked sessions, the session and turn they were forked from
+	ParentAirtableID string
+	ParentTurn       int
+	// kept out of the published storybook, either because it was played
+	// somewhere private or because participants opted out
+	Unlisted bool
+	// participants who opted out. it stays unlisted until each of them opts
+	// back in.
+	UnlistedBy []SlackUser
+	// the latest recap of the story, how many turns in it was made and the
+	// story item of the output it was made after, so a revised turn gets a
+	// fresh recap
+	Recap       string
+	RecapTurn   int
+	RecapOutput string
+	// for sessions started from the scenario library, the scenario they
+	// came from
+	ScenarioAirtableID string
+	// for sessions started in one of AI Dungeon's preset story modes, the
+	// mode and the character the creator plays. empty for custom prompts.
+	StoryMode     string
+	CharacterType string
+	PresetName    string
+	// anyone can play, not just the creator and their companions
+	Open bool
+	// an admin gave the creator their GP back
+	Refunded bool
 }
 
 type airtableSession struct {
-	AirtableID string `json:"id,omitempty"`
-	Fields     struct {
+	AirtableID  string     `json:"id,omitempty"`
+	CreatedTime *time.Time `json:"createdTime,omitempty"`
+	Fields      struct {
 		ThreadTimestamp string `json:"Thread Timestamp"`
+		ChannelID       string `json:"Channel ID"`
 		Creator         string
 		Companions      string
 		Cost            int  `json:"Cost (GP)"`
 		Paid            bool `json:"Paid?"`
 		Prompt          string
-		SessionID       int `json:"Session ID,omitempty"`
+		SessionID       int        `json:"Session ID,omitempty"`
+		PlayMode        string     `json:"Play Mode,omitempty"`
+		TurnOrder       string     `json:"Turn Order,omitempty"`
+		CurrentTurn     int        `json:"Current Turn,omitempty"`
+		TurnStartedAt   *time.Time `json:"Turn Started At,omitempty"`
+		VotePhase       string     `json:"Vote Phase,omitempty"`
+		VoteStartedAt   *time.Time `json:"Vote Phase Started At,omitempty"`
+		BallotTimestamp string     `json:"Ballot Timestamp,omitempty"`
+		Characters      string     `json:",omitempty"`
+		Status          string     `json:",omitempty"`
+		LastActiveAt    *time.Time `json:"Last Active At,omitempty"`
+		ParentSession   []string   `json:"Parent Session,omitempty"`
+		ParentTurn      int        `json:"Parent Turn,omitempty"`
+		Unlisted        bool       `json:"Unlisted?,omitempty"`
+		UnlistedBy      string     `json:"Unlisted By,omitempty"`
+		Recap           string     `json:",omitempty"`
+		RecapTurn       int        `json:"Recap Turn,omitempty"`
+		RecapOutput     string     `json:"Recap Output,omitempty"`
+		Scenario        []string   `json:",omitempty"`
+		StoryMode       string     `json:"Story Mode,omitempty"`
+		CharacterType   string     `json:"Character Type,omitempty"`
+		PresetName      string     `json:"Character Name,omitempty"`
+		Open            bool       `json:"Open?,omitempty"`
+		Refunded        bool       `json:"Refunded?,omitempty"`
 	} `json:"fields"`
 }
 
@@ -146,25 +246,133 @@ func sessionFromAirtable(as airtableSession) (Session, error) {
 		}
 	}
 
+	var turnOrder []SlackUser
+	if as.Fields.TurnOrder != "" {
+		turnOrder, err = SlackUsersFromString(as.Fields.TurnOrder)
+		if err != nil {
+			return Session{}, err
+		}
+	}
+
+	playMode := as.Fields.PlayMode
+	if playMode == "" {
+		playMode = PlayModeFree
+	}
+
+	var turnStartedAt time.Time
+	if as.Fields.TurnStartedAt != nil {
+		turnStartedAt = *as.Fields.TurnStartedAt
+	}
+
+	var characters []SlackUser
+	if as.Fields.Characters != "" {
+		characters, err = SlackUsersFromString(as.Fields.Characters)
+		if err != nil {
+			return Session{}, err
+		}
+	}
+
+	status := as.Fields.Status
+	if status == "" {
+		status = StatusActive
+	}
+
+	// sessions from before we tracked activity were last active when they
+	// were created, as far as we know
+	var lastActiveAt time.Time
+	if as.Fields.LastActiveAt != nil {
+		lastActiveAt = *as.Fields.LastActiveAt
+	} else if as.CreatedTime != nil {
+		lastActiveAt = *as.CreatedTime
+	}
+
+	var parentAirtableID string
+	if len(as.Fields.ParentSession) > 0 {
+		parentAirtableID = as.Fields.ParentSession[0]
+	}
+
+	var scenarioAirtableID string
+	if len(as.Fields.Scenario) > 0 {
+		scenarioAirtableID = as.Fields.Scenario[0]
+	}
+
+	var voteStartedAt time.Time
+	if as.Fields.VoteStartedAt != nil {
+		voteStartedAt = *as.Fields.VoteStartedAt
+	}
+
+	var unlistedBy []SlackUser
+	if as.Fields.UnlistedBy != "" {
+		unlistedBy, err = SlackUsersFromString(as.Fields.UnlistedBy)
+		if err != nil {
+			return Session{}, err
+		}
+	}
+
 	return Session{
 		AirtableID:      as.AirtableID,
 		ThreadTimestamp: as.Fields.ThreadTimestamp,
+		ChannelID:       as.Fields.ChannelID,
 		Creator:         creator,
 		Companions:      companions,
 		CostGP:          as.Fields.Cost,
 		Paid:            as.Fields.Paid,
 		Prompt:          as.Fields.Prompt,
 		SessionID:       as.Fields.SessionID,
+		PlayMode:        playMode,
+		TurnOrder:       turnOrder,
+		CurrentTurn:     as.Fields.CurrentTurn,
+		TurnStartedAt:   turnStartedAt,
+		VotePhase:       as.Fields.VotePhase,
+		VoteStartedAt:   voteStartedAt,
+		BallotTimestamp: as.Fields.BallotTimestamp,
+		Characters:      characters,
+		Status:          status,
+		LastActiveAt:    lastActiveAt,
+
+		ParentAirtableID: parentAirtableID,
+		ParentTurn:       as.Fields.ParentTurn,
+		Unlisted:         as.Fields.Unlisted,
+		UnlistedBy:       unlistedBy,
+		Recap:            as.Fields.Recap,
+		RecapTurn:        as.Fields.RecapTurn,
+		RecapOutput:      as.Fields.RecapOutput,
+
+		ScenarioAirtableID: scenarioAirtableID,
+		StoryMode:          as.Fields.StoryMode,
+		CharacterType:      as.Fields.CharacterType,
+		PresetName:         as.Fields.PresetName,
+		Open:               as.Fields.Open,
+		Refunded:           as.Fields.Refunded,
 	}, nil
 }
 
-func (db *DB) CreateSession(threadTs string, creator SlackUser, companions []SlackUser, costGP int, prompt string) (Session, error) {
+// CreateSession saves a new, unpaid session. only the fields describing how
+// the journey starts are saved, the rest are set as it's played.
+func (db *DB) CreateSession(session Session) (Session, error) {
 	as := airtableSession{}
-	as.Fields.ThreadTimestamp = threadTs
-	as.Fields.Creator = creator.ToString()
-	as.Fields.Companions = SlackUsersToString(companions)
-	as.Fields.Cost = costGP
-	as.Fields.Prompt = prompt
+	as.Fields.ThreadTimestamp = session.ThreadTimestamp
+	as.Fields.ChannelID = session.ChannelID
+	as.Fields.Creator = session.Creator.ToString()
+	as.Fields.Companions = SlackUsersToString(session.Companions)
+	as.Fields.Cost = session.CostGP
+	as.Fields.Prompt = session.Prompt
+
+	if session.ParentAirtableID != "" {
+		as.Fields.ParentSession = []string{session.ParentAirtableID}
+		as.Fields.ParentTurn = session.ParentTurn
+	}
+
+	if session.ScenarioAirtableID != "" {
+		as.Fields.Scenario = []string{session.ScenarioAirtableID}
+	}
+
+	as.Fields.StoryMode = session.StoryMode
+	as.Fields.CharacterType = session.CharacterType
+	as.Fields.PresetName = session.PresetName
+	as.Fields.Open = session.Open
+	as.Fields.Unlisted = session.Unlisted
+	as.Fields.UnlistedBy = SlackUsersToString(session.UnlistedBy)
 
 	if err := db.client.CreateRecord("Sessions", &as); err != nil {
 		return Session{}, err
@@ -173,6 +381,9 @@ func (db *DB) CreateSession(threadTs string, creator SlackUser, companions []Sla
 	return sessionFromAirtable(as)
 }
 
+// returned by GetSession when there's no session in a thread
+var ErrNoSession = errors.New("no session found")
+
 func (db *DB) GetSession(threadTs string) (Session, error) {
 	listParams := airtable.ListParameters{
 		// I can't find a great way to escape values here. The best
@@ -195,7 +406,7 @@ func (db *DB) GetSession(threadTs string) (Session, error) {
 	if len(airtableSessions) > 1 {
 		return Session{}, errors.New("too many sessions, non-unique timestamps")
 	} else if len(airtableSessions) == 0 {
-		return Session{}, errors.New("no session found")
+		return Session{}, ErrNoSession
 	}
 
 	return sessionFromAirtable(airtableSessions[0])
@@ -205,8 +416,331 @@ func (db *DB) MarkSessionPaidAndStarted(session Session, sessionID int) (Session
 	as := airtableSession{}
 
 	updatedFields := map[string]interface{}{
-		"Paid?":      true,
-		"Session ID": sessionID,
+		"Paid?":          true,
+		"Session ID":     sessionID,
+		"Last Active At": time.Now(),
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// Participants returns everyone allowed to play the session, creator first
+func (s Session) Participants() []SlackUser {
+	return append([]SlackUser{s.Creator}, s.Companions...)
+}
+
+// IsParticipant reports whether a user can play the session. anyone can play
+// open sessions.
+func (s Session) IsParticipant(user SlackUser) bool {
+	if s.Open {
+		return true
+	}
+
+	for _, participant := range s.Participants() {
+		if participant.Eq(user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (db *DB) UpdateSessionParticipants(session Session, creator SlackUser, companions []SlackUser) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Creator":    creator.ToString(),
+		"Companions": SlackUsersToString(companions),
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// CurrentPlayer returns whose turn it is. false if the session isn't taking
+// turns.
+func (s Session) CurrentPlayer() (SlackUser, bool) {
+	if s.PlayMode != PlayModeTurns || len(s.TurnOrder) == 0 {
+		return SlackUser{}, false
+	}
+
+	return s.TurnOrder[s.CurrentTurn%len(s.TurnOrder)], true
+}
+
+// CharacterName returns the name of the character the user is playing as.
+// false if they haven't registered one.
+func (s Session) CharacterName(user SlackUser) (string, bool) {
+	for _, character := range s.Characters {
+		if character.Eq(user) {
+			return character.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// SetSessionCharacter registers the name of the character a participant is
+// playing as, replacing any they had before
+func (db *DB) SetSessionCharacter(session Session, user SlackUser, name string) (Session, error) {
+	as := airtableSession{}
+
+	characters := []SlackUser{}
+	for _, character := range session.Characters {
+		if !character.Eq(user) {
+			characters = append(characters, character)
+		}
+	}
+	characters = append(characters, SlackUser{ID: user.ID, Name: name})
+
+	updatedFields := map[string]interface{}{
+		"Characters": SlackUsersToString(characters),
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// SetSessionPlayMode switches how inputs are accepted. turnOrder and
+// currentTurn are ignored unless mode is PlayModeTurns.
+func (db *DB) SetSessionPlayMode(session Session, mode string, turnOrder []SlackUser, currentTurn int) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Play Mode":             mode,
+		"Turn Order":            "",
+		"Current Turn":          0,
+		"Turn Started At":       nil,
+		"Vote Phase":            VotePhaseNone,
+		"Vote Phase Started At": nil,
+		"Ballot Timestamp":      "",
+	}
+
+	if mode == PlayModeTurns {
+		updatedFields["Turn Order"] = SlackUsersToString(turnOrder)
+		updatedFields["Current Turn"] = currentTurn
+		updatedFields["Turn Started At"] = time.Now()
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// AdvanceSessionTurn hands the turn to the next player in the turn order
+func (db *DB) AdvanceSessionTurn(session Session) (Session, error) {
+	as := airtableSession{}
+
+	nextTurn := 0
+	if len(session.TurnOrder) > 0 {
+		nextTurn = (session.CurrentTurn + 1) % len(session.TurnOrder)
+	}
+
+	updatedFields := map[string]interface{}{
+		"Current Turn":    nextTurn,
+		"Turn Started At": time.Now(),
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// SetSessionVotePhase moves a voting session's current round along.
+// ballotTs is the timestamp of the posted ballot, if any.
+func (db *DB) SetSessionVotePhase(session Session, phase, ballotTs string) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Vote Phase":            phase,
+		"Vote Phase Started At": time.Now(),
+		"Ballot Timestamp":      ballotTs,
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// ListSessionsInPlayMode returns all paid, active sessions using the given
+// play mode
+func (db *DB) ListSessionsInPlayMode(mode string) ([]Session, error) {
+	return db.listSessions(`AND({Play Mode} = "` + mode + `", {Paid?}, ` + isActiveFormula + `)`)
+}
+
+// ListIdleSessions returns all paid, active sessions nobody has played since
+// the given time
+func (db *DB) ListIdleSessions(since time.Time) ([]Session, error) {
+	return db.listSessions(`AND({Paid?}, ` + isActiveFormula + `, IS_BEFORE(` + lastActiveFormula + `, "` + since.UTC().Format(time.RFC3339) + `"))`)
+}
+
+// ListPublishableSessions returns all finished sessions nobody has unlisted
+func (db *DB) ListPublishableSessions() ([]Session, error) {
+	return db.listSessions(`AND({Paid?}, {Status} = "` + StatusEnded + `", NOT({Unlisted?}))`)
+}
+
+// ListSessionsActiveSince returns all paid sessions someone has played since
+// the given time
+func (db *DB) ListSessionsActiveSince(since time.Time) ([]Session, error) {
+	return db.listSessions(`AND({Paid?}, IS_AFTER(` + lastActiveFormula + `, "` + since.UTC().Format(time.RFC3339) + `"))`)
+}
+
+// ListOpenSessions returns all paid, active sessions anyone can play
+func (db *DB) ListOpenSessions() ([]Session, error) {
+	return db.listSessions(`AND({Open?}, {Paid?}, ` + isActiveFormula + `)`)
+}
+
+// SpentOnOpenSessions adds up what open sessions started since the given time
+// cost
+func (db *DB) SpentOnOpenSessions(since time.Time) (int, error) {
+	sessions, err := db.listSessions(`AND({Open?}, {Paid?}, IS_AFTER(CREATED_TIME(), "` + since.UTC().Format(time.RFC3339) + `"))`)
+	if err != nil {
+		return 0, err
+	}
+
+	spent := 0
+	for _, session := range sessions {
+		spent += session.CostGP
+	}
+
+	return spent, nil
+}
+
+// sessions from before we tracked status don't have one and are active
+const isActiveFormula = `OR({Status} = "", {Status} = "` + StatusActive + `")`
+
+// sessions from before we tracked activity were last active when they were
+// created
+const lastActiveFormula = `IF({Last Active At}, {Last Active At}, CREATED_TIME())`
+
+func (db *DB) listSessions(formula string) ([]Session, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: formula,
+	}
+
+	airtableSessions := []airtableSession{}
+	if err := db.client.ListRecords("Sessions", &airtableSessions, listParams); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, len(airtableSessions))
+	for i, as := range airtableSessions {
+		var err error
+		sessions[i], err = sessionFromAirtable(as)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, nil
+}
+
+// SetSessionStatus pauses, resumes or ends a session. resuming restarts the
+// clock on the session's inactivity and current turn, and ending counts as
+// the session's last activity.
+func (db *DB) SetSessionStatus(session Session, status string) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Status": status,
+	}
+
+	if status == StatusActive || status == StatusEnded {
+		updatedFields["Last Active At"] = time.Now()
+	}
+
+	if status == StatusActive && session.PlayMode == PlayModeTurns {
+		updatedFields["Turn Started At"] = time.Now()
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// MarkSessionRefunded notes that a session's creator got their GP back
+func (db *DB) MarkSessionRefunded(session Session) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Refunded?": true,
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// SetSessionUnlisted opts a session out of (or back into) the storybook on
+// behalf of a participant. it's only listed again once everyone who opted out
+// has opted back in.
+func (db *DB) SetSessionUnlisted(session Session, user SlackUser, unlisted bool) (Session, error) {
+	as := airtableSession{}
+
+	unlistedBy := []SlackUser{}
+	for _, u := range session.UnlistedBy {
+		if !u.Eq(user) {
+			unlistedBy = append(unlistedBy, u)
+		}
+	}
+	if unlisted {
+		unlistedBy = append(unlistedBy, user)
+	}
+
+	updatedFields := map[string]interface{}{
+		"Unlisted?":   len(unlistedBy) > 0,
+		"Unlisted By": SlackUsersToString(unlistedBy),
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// SetSessionRecap caches the latest recap of the story
+func (db *DB) SetSessionRecap(session Session, recap string, turn int, output StoryItem) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Recap":        recap,
+		"Recap Turn":   turn,
+		"Recap Output": output.AirtableID,
+	}
+
+	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
+		return Session{}, err
+	}
+
+	return sessionFromAirtable(as)
+}
+
+// MarkSessionActive records that the session was just played
+func (db *DB) MarkSessionActive(session Session) (Session, error) {
+	as := airtableSession{}
+
+	updatedFields := map[string]interface{}{
+		"Last Active At": time.Now(),
 	}
 
 	if err := db.client.UpdateRecord("Sessions", session.AirtableID, updatedFields, &as); err != nil {
@@ -216,20 +750,77 @@ func (db *DB) MarkSessionPaidAndStarted(session Session, sessionID int) (Session
 	return sessionFromAirtable(as)
 }
 
+type StoryItem struct {
+	AirtableID string
+	// the thread of the session it's part of
+	ThreadTimestamp string
+	Type            string
+	Author          *SlackUser
+	Value           string
+	// for inputs, the text actually sent to the engine if it differs from
+	// what the author wrote
+	EngineInput string
+	// for inputs, whether it was an action, dialogue or story text
+	Mode string
+	// for outputs, the timestamp of the slack message it was posted in
+	SlackTimestamp string
+	// undone, retried or altered items are kept around, but marked as
+	// superseded
+	Superseded bool
+	CreatedAt  time.Time
+}
+
 type airtableStoryItem struct {
-	AirtableID string `json:"id,omitempty"`
-	Fields     struct {
-		Session []string
-		Type    string
-		Author  string
-		Value   string
+	AirtableID  string     `json:"id,omitempty"`
+	CreatedTime *time.Time `json:"createdTime,omitempty"`
+	Fields      struct {
+		Session         []string
+		ThreadTimestamp string `json:"Thread Timestamp"`
+		Type            string
+		Author          string
+		Value           string
+		EngineInput     string `json:"Engine Input,omitempty"`
+		Mode            string `json:",omitempty"`
+		SlackTimestamp  string `json:"Slack Timestamp,omitempty"`
+		Superseded      bool   `json:"Superseded?,omitempty"`
 	} `json:"fields"`
 }
 
+func storyItemFromAirtable(asi airtableStoryItem) (StoryItem, error) {
+	var author *SlackUser
+	if asi.Fields.Author != "" {
+		user, err := SlackUserFromString(asi.Fields.Author)
+		if err != nil {
+			return StoryItem{}, err
+		}
+
+		author = &user
+	}
+
+	var createdAt time.Time
+	if asi.CreatedTime != nil {
+		createdAt = *asi.CreatedTime
+	}
+
+	return StoryItem{
+		AirtableID:      asi.AirtableID,
+		ThreadTimestamp: asi.Fields.ThreadTimestamp,
+		Type:            asi.Fields.Type,
+		Author:          author,
+		Value:           asi.Fields.Value,
+		EngineInput:     asi.Fields.EngineInput,
+		Mode:            asi.Fields.Mode,
+		SlackTimestamp:  asi.Fields.SlackTimestamp,
+		Superseded:      asi.Fields.Superseded,
+		CreatedAt:       createdAt,
+	}, nil
+}
+
 // author should be nil
 func (db *DB) CreateStoryItem(session Session, itemType string, author *SlackUser, value string) error {
 	si := airtableStoryItem{}
 	si.Fields.Session = []string{session.AirtableID}
+	si.Fields.ThreadTimestamp = session.ThreadTimestamp
 	si.Fields.Type = itemType
 
 	if author != nil {
@@ -244,3 +835,949 @@ func (db *DB) CreateStoryItem(session Session, itemType string, author *SlackUse
 
 	return nil
 }
+
+// CreateInputStoryItem records a participant's input exactly as they wrote it
+// along with its mode and the text that was sent to the engine for it
+func (db *DB) CreateInputStoryItem(session Session, author SlackUser, mode, input, engineInput string) (StoryItem, error) {
+	si := airtableStoryItem{}
+	si.Fields.Session = []string{session.AirtableID}
+	si.Fields.ThreadTimestamp = session.ThreadTimestamp
+	si.Fields.Type = "Input"
+	si.Fields.Author = author.ToString()
+	si.Fields.Value = input
+	si.Fields.EngineInput = engineInput
+	si.Fields.Mode = mode
+
+	if err := db.client.CreateRecord("Story Items", &si); err != nil {
+		return StoryItem{}, err
+	}
+
+	return storyItemFromAirtable(si)
+}
+
+// A turn of the story: the output the engine generated and any inputs that led
+// to it. the journey's opening is turn 0 and has no inputs.
+type Turn struct {
+	Inputs []StoryItem
+	Output StoryItem
+}
+
+// StoryTurns groups a session's story items into turns, leaving out anything
+// superseded. inputs still waiting on an output aren't part of a turn.
+func StoryTurns(items []StoryItem) []Turn {
+	turns := []Turn{}
+	inputs := []StoryItem{}
+	for _, item := range items {
+		if item.Superseded {
+			continue
+		}
+
+		switch item.Type {
+		case "Input":
+			inputs = append(inputs, item)
+		case "Output":
+			turns = append(turns, Turn{Inputs: inputs, Output: item})
+			inputs = []StoryItem{}
+		}
+	}
+
+	return turns
+}
+
+// CreateOutputStoryItem records an output from the engine along with the
+// slack message it was posted in, so it can be edited later
+func (db *DB) CreateOutputStoryItem(session Session, output, slackTs string) error {
+	si := airtableStoryItem{}
+	si.Fields.Session = []string{session.AirtableID}
+	si.Fields.ThreadTimestamp = session.ThreadTimestamp
+	si.Fields.Type = "Output"
+	si.Fields.Value = output
+	si.Fields.SlackTimestamp = slackTs
+
+	if err := db.client.CreateRecord("Story Items", &si); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *DB) MarkStoryItemSuperseded(item StoryItem) error {
+	asi := airtableStoryItem{}
+
+	updatedFields := map[string]interface{}{
+		"Superseded?": true,
+	}
+
+	return db.client.UpdateRecord("Story Items", item.AirtableID, updatedFields, &asi)
+}
+
+// GetStoryItems returns all of a session's story items, oldest first. Items
+// created before story items tracked their thread timestamp aren't included.
+func (db *DB) GetStoryItems(session Session) ([]StoryItem, error) {
+	// see GetSession for notes on escaping
+	items, err := db.listStoryItems(`{Thread Timestamp} = "` + session.ThreadTimestamp + `"`)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	return items, nil
+}
+
+func (db *DB) listStoryItems(formula string) ([]StoryItem, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: formula,
+	}
+
+	airtableStoryItems := []airtableStoryItem{}
+	if err := db.client.ListRecords("Story Items", &airtableStoryItems, listParams); err != nil {
+		return nil, err
+	}
+
+	items := make([]StoryItem, len(airtableStoryItems))
+	for i, asi := range airtableStoryItems {
+		var err error
+		items[i], err = storyItemFromAirtable(asi)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// A ready-made journey from the scenario library. Prompts are templates with
+// placeholders like {name} and {item} that players fill in when they start
+// one.
+type Scenario struct {
+	AirtableID string
+	// short, url-style name players start it with, ex. "zombie-soldier"
+	Name   string
+	Tags   []string
+	Prompt string
+	// 0 if it costs the usual amount
+	CostGP int
+}
+
+type airtableScenario struct {
+	AirtableID string `json:"id,omitempty"`
+	Fields     struct {
+		Name   string
+		Tags   []string `json:",omitempty"`
+		Prompt string
+		Cost   int `json:"Cost (GP),omitempty"`
+	} `json:"fields"`
+}
+
+func scenarioFromAirtable(as airtableScenario) Scenario {
+	return Scenario{
+		AirtableID: as.AirtableID,
+		Name:       as.Fields.Name,
+		Tags:       as.Fields.Tags,
+		Prompt:     as.Fields.Prompt,
+		CostGP:     as.Fields.Cost,
+	}
+}
+
+// GetScenario finds a scenario in the library by name. name is only ever
+// letters, numbers and dashes, so it's safe to put in the formula.
+func (db *DB) GetScenario(name string) (Scenario, error) {
+	scenarios, err := db.listScenarios(`LOWER({Name}) = "` + strings.ToLower(name) + `"`)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	if len(scenarios) == 0 {
+		return Scenario{}, errors.New("no scenario found")
+	}
+
+	return scenarios[0], nil
+}
+
+// ListScenarios returns the scenarios in the library with the given tag, or
+// all of them if tag is empty
+func (db *DB) ListScenarios(tag string) ([]Scenario, error) {
+	formula := ""
+	if tag != "" {
+		formula = `FIND(",` + strings.ToLower(tag) + `,", "," & LOWER(ARRAYJOIN({Tags}, ",")) & ",")`
+	}
+
+	return db.listScenarios(formula)
+}
+
+func (db *DB) listScenarios(formula string) ([]Scenario, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: formula,
+		Sort: []airtable.SortParameter{
+			{Field: "Name"},
+		},
+	}
+
+	airtableScenarios := []airtableScenario{}
+	if err := db.client.ListRecords("Scenarios", &airtableScenarios, listParams); err != nil {
+		return nil, err
+	}
+
+	scenarios := make([]Scenario, len(airtableScenarios))
+	for i, as := range airtableScenarios {
+		scenarios[i] = scenarioFromAirtable(as)
+	}
+
+	return scenarios, nil
+}
+
+// Abilities on a character sheet, in the order they're shown
+var Stats = []string{"STR", "DEX", "CON", "INT", "WIS", "CHA"}
+
+// A player's character in a session, tracked alongside the story
+type CharacterSheet struct {
+	AirtableID string
+	Player     SlackUser
+	Name       string
+	// keyed by the names in Stats
+	Stats     map[string]int
+	HP        int
+	MaxHP     int
+	Inventory []string
+}
+
+type airtableCharacterSheet struct {
+	AirtableID string `json:"id,omitempty"`
+	Fields     struct {
+		Session         []string
+		ThreadTimestamp string `json:"Thread Timestamp"`
+		Player          string
+		Name            string
+		// ex. "STR 10, DEX 14, CON 10, INT 8, WIS 10, CHA 12"
+		Stats string
+		HP    int
+		MaxHP int `json:"Max HP"`
+		// one item per line, since items can have commas in them
+		Inventory string
+	} `json:"fields"`
+}
+
+var statRegex = regexp.MustCompile(`([A-Z]+) (-?[0-9]+)`)
+
+func characterSheetFromAirtable(acs airtableCharacterSheet) (CharacterSheet, error) {
+	player, err := SlackUserFromString(acs.Fields.Player)
+	if err != nil {
+		return CharacterSheet{}, err
+	}
+
+	stats := map[string]int{}
+	for _, match := range statRegex.FindAllStringSubmatch(acs.Fields.Stats, -1) {
+		value, err := strconv.Atoi(match[2])
+		if err != nil {
+			return CharacterSheet{}, err
+		}
+
+		stats[match[1]] = value
+	}
+
+	inventory := []string{}
+	for _, item := range strings.Split(acs.Fields.Inventory, "\n") {
+		if item = strings.TrimSpace(item); item != "" {
+			inventory = append(inventory, item)
+		}
+	}
+
+	return CharacterSheet{
+		AirtableID: acs.AirtableID,
+		Player:     player,
+		Name:       acs.Fields.Name,
+		Stats:      stats,
+		HP:         acs.Fields.HP,
+		MaxHP:      acs.Fields.MaxHP,
+		Inventory:  inventory,
+	}, nil
+}
+
+func (s CharacterSheet) statsString() string {
+	stats := make([]string, len(Stats))
+	for i, stat := range Stats {
+		stats[i] = fmt.Sprint(stat, " ", s.Stats[stat])
+	}
+
+	return strings.Join(stats, ", ")
+}
+
+// GetCharacterSheets returns the character sheets of everyone in a session who
+// has one
+func (db *DB) GetCharacterSheets(session Session) ([]CharacterSheet, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: `{Thread Timestamp} = "` + session.ThreadTimestamp + `"`,
+	}
+
+	airtableSheets := []airtableCharacterSheet{}
+	if err := db.client.ListRecords("Character Sheets", &airtableSheets, listParams); err != nil {
+		return nil, err
+	}
+
+	sheets := make([]CharacterSheet, len(airtableSheets))
+	for i, acs := range airtableSheets {
+		var err error
+		sheets[i], err = characterSheetFromAirtable(acs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sheets, nil
+}
+
+// SaveCharacterSheet creates the sheet if it's new, otherwise updates it
+func (db *DB) SaveCharacterSheet(session Session, sheet CharacterSheet) (CharacterSheet, error) {
+	acs := airtableCharacterSheet{}
+
+	if sheet.AirtableID == "" {
+		acs.Fields.Session = []string{session.AirtableID}
+		acs.Fields.ThreadTimestamp = session.ThreadTimestamp
+		acs.Fields.Player = sheet.Player.ToString()
+		acs.Fields.Name = sheet.Name
+		acs.Fields.Stats = sheet.statsString()
+		acs.Fields.HP = sheet.HP
+		acs.Fields.MaxHP = sheet.MaxHP
+		acs.Fields.Inventory = strings.Join(sheet.Inventory, "\n")
+
+		if err := db.client.CreateRecord("Character Sheets", &acs); err != nil {
+			return CharacterSheet{}, err
+		}
+
+		return characterSheetFromAirtable(acs)
+	}
+
+	updatedFields := map[string]interface{}{
+		"Name":      sheet.Name,
+		"Stats":     sheet.statsString(),
+		"HP":        sheet.HP,
+		"Max HP":    sheet.MaxHP,
+		"Inventory": strings.Join(sheet.Inventory, "\n"),
+	}
+
+	if err := db.client.UpdateRecord("Character Sheets", sheet.AirtableID, updatedFields, &acs); err != nil {
+		return CharacterSheet{}, err
+	}
+
+	return characterSheetFromAirtable(acs)
+}
+
+// Kinds of lore in a channel's world
+const (
+	// facts about the world in general, always drawn on
+	LoreKindLore = "Lore"
+	// a recurring character, drawn on when they come up
+	LoreKindNPC = "NPC"
+	// a recurring place, drawn on when it comes up
+	LoreKindPlace = "Place"
+)
+
+// Whether lore is part of the world yet
+const (
+	// an admin added or approved it
+	LoreStatusApproved = "Approved"
+	// someone suggested it, or it came up in a story, and it's waiting on
+	// an admin
+	LoreStatusProposed = "Proposed"
+)
+
+// An entry in the shared world of a channel's journeys
+type Lore struct {
+	AirtableID  string
+	ChannelID   string
+	Kind        string
+	Name        string
+	Description string
+	// other words that mean the entry has come up, ex. "the dragon"
+	Keywords []string
+	Status   string
+	// nil if it was proposed by a story's output
+	ProposedBy *SlackUser
+	// the journey it came from, if any
+	ThreadTimestamp string
+}
+
+type airtableLore struct {
+	AirtableID string `json:"id,omitempty"`
+	Fields     struct {
+		ChannelID       string `json:"Channel ID"`
+		Kind            string
+		Name            string
+		Description     string
+		Keywords        string `json:",omitempty"`
+		Status          string
+		ProposedBy      string `json:"Proposed By,omitempty"`
+		ThreadTimestamp string `json:"Thread Timestamp,omitempty"`
+	} `json:"fields"`
+}
+
+func loreFromAirtable(al airtableLore) (Lore, error) {
+	var proposedBy *SlackUser
+	if al.Fields.ProposedBy != "" {
+		user, err := SlackUserFromString(al.Fields.ProposedBy)
+		if err != nil {
+			return Lore{}, err
+		}
+
+		proposedBy = &user
+	}
+
+	keywords := []string{}
+	for _, keyword := range strings.Split(al.Fields.Keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	return Lore{
+		AirtableID:      al.AirtableID,
+		ChannelID:       al.Fields.ChannelID,
+		Kind:            al.Fields.Kind,
+		Name:            al.Fields.Name,
+		Description:     al.Fields.Description,
+		Keywords:        keywords,
+		Status:          al.Fields.Status,
+		ProposedBy:      proposedBy,
+		ThreadTimestamp: al.Fields.ThreadTimestamp,
+	}, nil
+}
+
+func (db *DB) CreateLore(lore Lore) (Lore, error) {
+	al := airtableLore{}
+	al.Fields.ChannelID = lore.ChannelID
+	al.Fields.Kind = lore.Kind
+	al.Fields.Name = lore.Name
+	al.Fields.Description = lore.Description
+	al.Fields.Keywords = strings.Join(lore.Keywords, ", ")
+	al.Fields.Status = lore.Status
+	al.Fields.ThreadTimestamp = lore.ThreadTimestamp
+
+	if lore.ProposedBy != nil {
+		al.Fields.ProposedBy = lore.ProposedBy.ToString()
+	}
+
+	if err := db.client.CreateRecord("Lore", &al); err != nil {
+		return Lore{}, err
+	}
+
+	return loreFromAirtable(al)
+}
+
+// ListLore returns a channel's lore with the given status, or all of it if
+// status is empty
+func (db *DB) ListLore(channelID, status string) ([]Lore, error) {
+	formula := `{Channel ID} = "` + channelID + `"`
+	if status != "" {
+		formula = `AND(` + formula + `, {Status} = "` + status + `")`
+	}
+
+	listParams := airtable.ListParameters{
+		FilterByFormula: formula,
+		Sort: []airtable.SortParameter{
+			{Field: "Name"},
+		},
+	}
+
+	airtableLores := []airtableLore{}
+	if err := db.client.ListRecords("Lore", &airtableLores, listParams); err != nil {
+		return nil, err
+	}
+
+	lores := make([]Lore, len(airtableLores))
+	for i, al := range airtableLores {
+		var err error
+		lores[i], err = loreFromAirtable(al)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lores, nil
+}
+
+func (db *DB) SetLoreStatus(lore Lore, status string) (Lore, error) {
+	al := airtableLore{}
+
+	updatedFields := map[string]interface{}{
+		"Status": status,
+	}
+
+	if err := db.client.UpdateRecord("Lore", lore.AirtableID, updatedFields, &al); err != nil {
+		return Lore{}, err
+	}
+
+	return loreFromAirtable(al)
+}
+
+func (db *DB) DeleteLore(lore Lore) error {
+	return db.client.DestroyRecord("Lore", lore.AirtableID)
+}
+
+// ListSessionsWithParticipant returns every paid session a slack user started
+// or joined. anyone can play open sessions, so they count once the user has
+// played in them, going by their inputs (as from ListInputs).
+func (db *DB) ListSessionsWithParticipant(userID string, inputs []StoryItem) ([]Session, error) {
+	sessions, err := db.listSessions(`AND({Paid?}, OR(FIND("<@` + userID + `>", {Creator}), FIND("<@` + userID + `>", {Companions}), {Open?}))`)
+	if err != nil {
+		return nil, err
+	}
+
+	playedIn := map[string]bool{}
+	for _, input := range inputs {
+		playedIn[input.ThreadTimestamp] = true
+	}
+
+	participated := []Session{}
+	for _, session := range sessions {
+		joined := session.Creator.ID == userID
+		for _, companion := range session.Companions {
+			joined = joined || companion.ID == userID
+		}
+
+		if session.Open && !joined && !playedIn[session.ThreadTimestamp] {
+			continue
+		}
+
+		participated = append(participated, session)
+	}
+
+	return participated, nil
+}
+
+// ListInputs returns every input a slack user has made, across all sessions
+func (db *DB) ListInputs(userID string) ([]StoryItem, error) {
+	return db.listStoryItems(`AND({Type} = "Input", NOT({Superseded?}), FIND("<@` + userID + `>", {Author}))`)
+}
+
+// An achievement or weekly quest a player has earned
+type Achievement struct {
+	AirtableID string
+	Player     SlackUser
+	Name       string
+	EarnedAt   time.Time
+	// the journey it was earned in, if any
+	ThreadTimestamp string
+}
+
+type airtableAchievement struct {
+	AirtableID  string     `json:"id,omitempty"`
+	CreatedTime *time.Time `json:"createdTime,omitempty"`
+	Fields      struct {
+		Player          string
+		Name            string
+		ThreadTimestamp string `json:"Thread Timestamp,omitempty"`
+	} `json:"fields"`
+}
+
+func achievementFromAirtable(aa airtableAchievement) (Achievement, error) {
+	player, err := SlackUserFromString(aa.Fields.Player)
+	if err != nil {
+		return Achievement{}, err
+	}
+
+	var earnedAt time.Time
+	if aa.CreatedTime != nil {
+		earnedAt = *aa.CreatedTime
+	}
+
+	return Achievement{
+		AirtableID:      aa.AirtableID,
+		Player:          player,
+		Name:            aa.Fields.Name,
+		EarnedAt:        earnedAt,
+		ThreadTimestamp: aa.Fields.ThreadTimestamp,
+	}, nil
+}
+
+func (db *DB) CreateAchievement(achievement Achievement) (Achievement, error) {
+	aa := airtableAchievement{}
+	aa.Fields.Player = achievement.Player.ToString()
+	aa.Fields.Name = achievement.Name
+	aa.Fields.ThreadTimestamp = achievement.ThreadTimestamp
+
+	if err := db.client.CreateRecord("Achievements", &aa); err != nil {
+		return Achievement{}, err
+	}
+
+	return achievementFromAirtable(aa)
+}
+
+// ListAchievements returns everything a slack user has earned, oldest first
+func (db *DB) ListAchievements(userID string) ([]Achievement, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: `FIND("<@` + userID + `>", {Player})`,
+	}
+
+	airtableAchievements := []airtableAchievement{}
+	if err := db.client.ListRecords("Achievements", &airtableAchievements, listParams); err != nil {
+		return nil, err
+	}
+
+	achievements := make([]Achievement, len(airtableAchievements))
+	for i, aa := range airtableAchievements {
+		var err error
+		achievements[i], err = achievementFromAirtable(aa)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(achievements, func(i, j int) bool {
+		return achievements[i].EarnedAt.Before(achievements[j].EarnedAt)
+	})
+
+	return achievements, nil
+}
+
+type airtableJobRun struct {
+	AirtableID string `json:"id,omitempty"`
+	Fields     struct {
+		Job   string
+		RanAt *time.Time `json:"Ran At,omitempty"`
+	} `json:"fields"`
+}
+
+// LastJobRun returns when a scheduled job last ran, or the zero time if it
+// never has. jobs are named in code, so the name is safe to put in the
+// formula.
+func (db *DB) LastJobRun(job string) (time.Time, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: `{Job} = "` + job + `"`,
+		Sort: []airtable.SortParameter{
+			{Field: "Ran At", ShouldSortDesc: true},
+		},
+		MaxRecords: 1,
+	}
+
+	runs := []airtableJobRun{}
+	if err := db.client.ListRecords("Job Runs", &runs, listParams); err != nil {
+		return time.Time{}, err
+	}
+
+	if len(runs) == 0 || runs[0].Fields.RanAt == nil {
+		return time.Time{}, nil
+	}
+
+	return *runs[0].Fields.RanAt, nil
+}
+
+// RecordJobRun notes that a scheduled job ran, so it isn't run again after a
+// restart
+func (db *DB) RecordJobRun(job string, ranAt time.Time) error {
+	run := airtableJobRun{}
+	run.Fields.Job = job
+	run.Fields.RanAt = &ranAt
+
+	return db.client.CreateRecord("Job Runs", &run)
+}
+
+// How many of something a slack user has, ex. turns played
+type UserCount struct {
+	User  SlackUser
+	Count int
+}
+
+// A player's history across all their journeys
+type PlayerStats struct {
+	JourneysStarted int
+	JourneysJoined  int
+	TurnsPlayed     int
+	// for the journeys they started
+	GPSpent int
+	// the people they've been on the most journeys with, most first
+	FavoriteCompanions []UserCount
+}
+
+// GetPlayerStats adds up a slack user's history across all their journeys
+func (db *DB) GetPlayerStats(userID string) (PlayerStats, error) {
+	inputs, err := db.ListInputs(userID)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+
+	sessions, err := db.ListSessionsWithParticipant(userID, inputs)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+
+	stats := PlayerStats{
+		TurnsPlayed: len(inputs),
+	}
+
+	companions := map[string]*UserCount{}
+	for _, session := range sessions {
+		if session.Creator.ID == userID {
+			stats.JourneysStarted++
+			// refunded journeys didn't cost anything in the end
+			if !session.Refunded {
+				stats.GPSpent += session.CostGP
+			}
+		} else {
+			stats.JourneysJoined++
+		}
+
+		for _, participant := range session.Participants() {
+			if participant.ID == userID {
+				continue
+			}
+
+			if companions[participant.ID] == nil {
+				companions[participant.ID] = &UserCount{User: participant}
+			}
+			companions[participant.ID].Count++
+		}
+	}
+
+	stats.FavoriteCompanions = rankUserCounts(companions)
+
+	return stats, nil
+}
+
+// TopPlayers ranks slack users by how many turns they've played since the
+// given time (or ever, if it's zero), most first
+func (db *DB) TopPlayers(since time.Time, limit int) ([]UserCount, error) {
+	inputs, err := db.listStoryItems(createdSinceFormula(`{Type} = "Input"`, since))
+	if err != nil {
+		return nil, err
+	}
+
+	players := map[string]*UserCount{}
+	for _, input := range inputs {
+		if input.Author == nil {
+			continue
+		}
+
+		if players[input.Author.ID] == nil {
+			players[input.Author.ID] = &UserCount{User: *input.Author}
+		}
+		players[input.Author.ID].Count++
+	}
+
+	ranked := rankUserCounts(players)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// A session and how many turns it's had
+type JourneyLength struct {
+	Session Session
+	Turns   int
+}
+
+// LongestJourneys ranks sessions by how many turns were played in them since
+// the given time (or ever, if it's zero), most first
+func (db *DB) LongestJourneys(since time.Time, limit int) ([]JourneyLength, error) {
+	// counting inputs rather than outputs leaves out each journey's opening
+	inputs, err := db.listStoryItems(createdSinceFormula(`{Type} = "Input"`, since))
+	if err != nil {
+		return nil, err
+	}
+
+	turns := map[string]int{}
+	for _, input := range inputs {
+		// items from before story items tracked their thread can't be
+		// tied to a journey
+		if input.ThreadTimestamp == "" {
+			continue
+		}
+
+		turns[input.ThreadTimestamp]++
+	}
+
+	threads := make([]string, 0, len(turns))
+	for thread := range turns {
+		threads = append(threads, thread)
+	}
+
+	sort.Slice(threads, func(i, j int) bool {
+		if turns[threads[i]] != turns[threads[j]] {
+			return turns[threads[i]] > turns[threads[j]]
+		}
+
+		return threads[i] < threads[j]
+	})
+
+	journeys := []JourneyLength{}
+	for _, thread := range threads {
+		if len(journeys) == limit {
+			break
+		}
+
+		session, err := db.GetSession(thread)
+		if err == ErrNoSession {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		journeys = append(journeys, JourneyLength{Session: session, Turns: turns[thread]})
+	}
+
+	return journeys, nil
+}
+
+// superseded items don't count towards anything
+func createdSinceFormula(formula string, since time.Time) string {
+	formula = `AND(` + formula + `, NOT({Superseded?})`
+	if !since.IsZero() {
+		formula += `, IS_AFTER(CREATED_TIME(), "` + since.UTC().Format(time.RFC3339) + `")`
+	}
+
+	return formula + `)`
+}
+
+func rankUserCounts(counts map[string]*UserCount) []UserCount {
+	ranked := make([]UserCount, 0, len(counts))
+	for _, count := range counts {
+		ranked = append(ranked, *count)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+
+		return ranked[i].User.ID < ranked[j].User.ID
+	})
+
+	return ranked
+}
+
+// Something worth keeping a record of, ex. content that was blocked by
+// moderation
+type AuditRecord struct {
+	AirtableID string
+	Action     string
+	// who did it, if anyone
+	Actor           *SlackUser
+	ChannelID       string
+	ThreadTimestamp string
+	Details         string
+	CreatedAt       time.Time
+}
+
+type airtableAuditRecord struct {
+	AirtableID  string     `json:"id,omitempty"`
+	CreatedTime *time.Time `json:"createdTime,omitempty"`
+	Fields      struct {
+		Action          string
+		Actor           string `json:",omitempty"`
+		ChannelID       string `json:"Channel ID,omitempty"`
+		ThreadTimestamp string `json:"Thread Timestamp,omitempty"`
+		Details         string `json:",omitempty"`
+	} `json:"fields"`
+}
+
+func auditRecordFromAirtable(aar airtableAuditRecord) (AuditRecord, error) {
+	var actor *SlackUser
+	if aar.Fields.Actor != "" {
+		user, err := SlackUserFromString(aar.Fields.Actor)
+		if err != nil {
+			return AuditRecord{}, err
+		}
+
+		actor = &user
+	}
+
+	var createdAt time.Time
+	if aar.CreatedTime != nil {
+		createdAt = *aar.CreatedTime
+	}
+
+	return AuditRecord{
+		AirtableID:      aar.AirtableID,
+		Action:          aar.Fields.Action,
+		Actor:           actor,
+		ChannelID:       aar.Fields.ChannelID,
+		ThreadTimestamp: aar.Fields.ThreadTimestamp,
+		Details:         aar.Fields.Details,
+		CreatedAt:       createdAt,
+	}, nil
+}
+
+func (db *DB) CreateAuditRecord(record AuditRecord) (AuditRecord, error) {
+	aar := airtableAuditRecord{}
+	aar.Fields.Action = record.Action
+	if record.Actor != nil {
+		aar.Fields.Actor = record.Actor.ToString()
+	}
+	aar.Fields.ChannelID = record.ChannelID
+	aar.Fields.ThreadTimestamp = record.ThreadTimestamp
+	aar.Fields.Details = record.Details
+
+	if err := db.client.CreateRecord("Audit Log", &aar); err != nil {
+		return AuditRecord{}, err
+	}
+
+	return auditRecordFromAirtable(aar)
+}
+
+type airtableBan struct {
+	AirtableID string `json:"id,omitempty"`
+	Fields     struct {
+		User string
+	} `json:"fields"`
+}
+
+// ListBans returns everyone who's been banned from playing
+func (db *DB) ListBans() ([]SlackUser, error) {
+	bans := []airtableBan{}
+	if err := db.client.ListRecords("Bans", &bans); err != nil {
+		return nil, err
+	}
+
+	users := make([]SlackUser, len(bans))
+	for i, ban := range bans {
+		var err error
+		users[i], err = SlackUserFromString(ban.Fields.User)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return users, nil
+}
+
+func (db *DB) CreateBan(user SlackUser) error {
+	ban := airtableBan{}
+	ban.Fields.User = user.ToString()
+
+	return db.client.CreateRecord("Bans", &ban)
+}
+
+// DeleteBan lifts every ban on a slack user. false if they weren't banned.
+func (db *DB) DeleteBan(userID string) (bool, error) {
+	listParams := airtable.ListParameters{
+		FilterByFormula: `FIND("<@` + userID + `>", {User})`,
+	}
+
+	bans := []airtableBan{}
+	if err := db.client.ListRecords("Bans", &bans, listParams); err != nil {
+		return false, err
+	}
+
+	for _, ban := range bans {
+		if err := db.client.DestroyRecord("Bans", ban.AirtableID); err != nil {
+			return false, err
+		}
+	}
+
+	return len(bans) > 0, nil
+}
+
+// CountTurnsSince counts the inputs and vote proposals a slack user has made
+// since the given time
+func (db *DB) CountTurnsSince(userID string, since time.Time) (int, error) {
+	items, err := db.listStoryItems(createdSinceFormula(`OR({Type} = "Input", {Type} = "Proposal"), FIND("<@`+userID+`>", {Author})`, since))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(items), nil
+}
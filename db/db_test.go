@@ -0,0 +1,33 @@
+package db
+
+import "testing"
+
+func TestSessionCharactersRoundTrip(t *testing.T) {
+	characters := []SlackUser{
+		{ID: "U1", Name: "Sir Bob"},
+		{ID: "U2", Name: "Jenny"},
+		{ID: "U3", Name: "Old Man Willow"},
+	}
+
+	as := airtableSession{}
+	as.Fields.Creator = SlackUser{ID: "U1", Name: "bob"}.ToString()
+	for i := 0; i < 3; i++ {
+		as.Fields.Characters = SlackUsersToString(characters)
+
+		session, err := sessionFromAirtable(as)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(session.Characters) != len(characters) {
+			t.Fatalf("got %d characters, want %d", len(session.Characters), len(characters))
+		}
+		for j, character := range session.Characters {
+			if character != characters[j] {
+				t.Errorf("save %d: character %d = %+v, want %+v", i, j, character, characters[j])
+			}
+		}
+
+		characters = session.Characters
+	}
+}
@@ -0,0 +1,133 @@
+// Tabletop dice rolls, ex. "d20+3" or "2d6"
+package dice
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// keep rolls small enough to show in slack
+const (
+	MaxCount = 100
+	MaxSides = 1000
+)
+
+var notationRegex = regexp.MustCompile(`^([0-9]*)d([0-9]+)(?:([+-])([0-9]+))?$`)
+
+// Dice to roll, ex. 2d6+1 is two six sided dice with 1 added to the total
+type Dice struct {
+	Count    int
+	Sides    int
+	Modifier int
+}
+
+// A roll of some dice and what came up
+type Result struct {
+	Dice  Dice
+	Seed  int64
+	Rolls []int
+	Total int
+}
+
+// Parse reads dice notation like "d20", "2d6" or "d20+3"
+func Parse(notation string) (Dice, error) {
+	matches := notationRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(notation)))
+	if matches == nil {
+		return Dice{}, errors.New("not dice notation: " + notation)
+	}
+
+	d := Dice{Count: 1}
+
+	if matches[1] != "" {
+		d.Count, _ = strconv.Atoi(matches[1])
+	}
+	d.Sides, _ = strconv.Atoi(matches[2])
+
+	if matches[4] != "" {
+		d.Modifier, _ = strconv.Atoi(matches[4])
+		if matches[3] == "-" {
+			d.Modifier = -d.Modifier
+		}
+	}
+
+	if d.Count < 1 || d.Count > MaxCount {
+		return Dice{}, errors.New("can only roll 1 to " + strconv.Itoa(MaxCount) + " dice")
+	}
+
+	if d.Sides < 2 || d.Sides > MaxSides {
+		return Dice{}, errors.New("dice need 2 to " + strconv.Itoa(MaxSides) + " sides")
+	}
+
+	return d, nil
+}
+
+func (d Dice) String() string {
+	s := "d" + strconv.Itoa(d.Sides)
+	if d.Count != 1 {
+		s = strconv.Itoa(d.Count) + s
+	}
+
+	switch {
+	case d.Modifier > 0:
+		s += "+" + strconv.Itoa(d.Modifier)
+	case d.Modifier < 0:
+		s += strconv.Itoa(d.Modifier)
+	}
+
+	return s
+}
+
+// Roll rolls the dice. the same seed always gives the same result, so rolls
+// can be checked after the fact.
+func (d Dice) Roll(seed int64) Result {
+	r := rand.New(rand.NewSource(seed))
+
+	result := Result{
+		Dice:  d,
+		Seed:  seed,
+		Rolls: make([]int, d.Count),
+		Total: d.Modifier,
+	}
+
+	for i := range result.Rolls {
+		result.Rolls[i] = r.Intn(d.Sides) + 1
+		result.Total += result.Rolls[i]
+	}
+
+	return result
+}
+
+// String shows the work, ex. "d20+3: 14 + 3 = 17"
+func (r Result) String() string {
+	parts := make([]string, len(r.Rolls))
+	for i, roll := range r.Rolls {
+		parts[i] = strconv.Itoa(roll)
+	}
+
+	work := strings.Join(parts, " + ")
+	switch {
+	case r.Dice.Modifier > 0:
+		work += " + " + strconv.Itoa(r.Dice.Modifier)
+	case r.Dice.Modifier < 0:
+		work += " - " + strconv.Itoa(-r.Dice.Modifier)
+	}
+
+	if len(r.Rolls) == 1 && r.Dice.Modifier == 0 {
+		return r.Dice.String() + ": " + work
+	}
+
+	return r.Dice.String() + ": " + work + " = " + strconv.Itoa(r.Total)
+}
+
+// Modifier is the bonus (or penalty) a character gets from a stat, the
+// tabletop way: 10 is average and every 2 points either way is worth 1
+func Modifier(stat int) int {
+	if stat >= 10 {
+		return (stat - 10) / 2
+	}
+
+	return -((11 - stat) / 2)
+}
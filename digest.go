@@ -0,0 +1,192 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./db"
+	"./story"
+	"./transcript"
+)
+
+// WEEKLY DIGEST //
+
+// the job that posts the weekly digest, as recorded in the store
+const weeklyDigestJob = "weekly digest"
+
+// how many journeys make the digest
+const digestSize = 5
+
+// how much each thing counts towards a journey's place in the digest. a
+// reaction from the community says more than another turn.
+const (
+	digestReactionWeight    = 3
+	digestTurnWeight        = 1
+	digestParticipantWeight = 2
+)
+
+// when we last posted a digest, so the store only needs checking once a week
+var lastDigest time.Time
+
+// a journey's showing for the week
+type digestEntry struct {
+	session      db.Session
+	turns        int
+	participants int
+	reactions    int
+	excerpt      string
+}
+
+func (e digestEntry) score() int {
+	return e.reactions*digestReactionWeight + e.turns*digestTurnWeight + e.participants*digestParticipantWeight
+}
+
+// postWeeklyDigest shares last week's best journeys once a week
+func postWeeklyDigest(api *slack.Client, dbc *db.DB) {
+	if config().DigestChannelID == "" {
+		return
+	}
+
+	now := time.Now()
+	thisWeek := weekStart(now)
+	if !lastDigest.Before(thisWeek) {
+		return
+	}
+
+	lastRun, err := dbc.LastJobRun(weeklyDigestJob)
+	if err != nil {
+		log.Println("unable to check when the weekly digest was last posted:", err)
+		return
+	}
+
+	if !lastRun.Before(thisWeek) {
+		lastDigest = lastRun
+		return
+	}
+
+	// recorded up front so a problem posting doesn't have us trying again
+	// every minute
+	if err := dbc.RecordJobRun(weeklyDigestJob, now); err != nil {
+		log.Println("unable to record weekly digest:", err)
+		return
+	}
+	lastDigest = now
+
+	entries, err := rankWeek(api, dbc, thisWeek.AddDate(0, 0, -7), thisWeek)
+	if err != nil {
+		log.Println("unable to rank last week's journeys:", err)
+		return
+	}
+
+	if len(entries) == 0 {
+		log.Println("no journeys last week, skipping weekly digest")
+		return
+	}
+
+	lines := []string{":newspaper: *the week in dungeon*: last week's best journeys", ""}
+	for i, entry := range entries {
+		title := transcript.Title(entry.session.Prompt)
+
+		link, err := api.GetPermalink(&slack.PermalinkParameters{
+			Channel: entry.session.ChannelID,
+			Ts:      entry.session.ThreadTimestamp,
+		})
+		if err == nil {
+			title = "<" + link + "|" + title + ">"
+		}
+
+		lines = append(lines,
+			strconv.Itoa(i+1)+". *"+title+"* by "+transcript.Name(entry.session.Creator)+": "+
+				plural(entry.turns, "turn")+", "+plural(entry.participants, "player")+", "+plural(entry.reactions, "reaction"),
+			"> "+entry.excerpt,
+			"",
+		)
+	}
+
+	_, _, err = api.PostMessage(
+		config().DigestChannelID,
+		slack.MsgOptionText(strings.Join(lines, "\n"), false),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		log.Println("unable to post weekly digest:", err)
+	}
+}
+
+// rankWeek scores the journeys played between from and to, best first
+func rankWeek(api *slack.Client, dbc *db.DB, from, to time.Time) ([]digestEntry, error) {
+	sessions, err := dbc.ListSessionsActiveSince(from)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []digestEntry{}
+	for _, session := range sessions {
+		// sessions from before we tracked channels can't be linked to, and
+		// private play isn't ours to announce
+		if session.ChannelID == "" || session.Unlisted || !isPublicChannel(api, session.ChannelID) {
+			continue
+		}
+
+		items, err := dbc.GetStoryItems(session)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := digestEntry{session: session}
+
+		players := []db.SlackUser{}
+		outputs := []string{}
+		for _, item := range items {
+			if item.Superseded || item.CreatedAt.Before(from) || !item.CreatedAt.Before(to) {
+				continue
+			}
+
+			switch item.Type {
+			case "Input":
+				if item.Author != nil && !containsUser(players, *item.Author) {
+					players = append(players, *item.Author)
+				}
+			case "Output":
+				entry.turns++
+				outputs = append(outputs, item.Value)
+			}
+		}
+
+		if entry.turns == 0 {
+			continue
+		}
+
+		entry.participants = len(players)
+		entry.excerpt = strings.ReplaceAll(story.Summarize(strings.Join(outputs, "\n\n"), 2), "\n", " ")
+
+		reactions, err := api.GetReactions(slack.ItemRef{
+			Channel:   session.ChannelID,
+			Timestamp: session.ThreadTimestamp,
+		}, slack.GetReactionsParameters{Full: true})
+		if err != nil {
+			log.Println("unable to get reactions for", session.ThreadTimestamp, "-", err)
+		}
+
+		for _, reaction := range reactions {
+			entry.reactions += reaction.Count
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].score() > entries[j].score()
+	})
+
+	if len(entries) > digestSize {
+		entries = entries[:digestSize]
+	}
+
+	return entries, nil
+}
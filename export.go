@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./transcript"
+)
+
+// EXPORTING TRANSCRIPTS //
+
+// when someone wants the journey so far as a file. defaults to markdown.
+// examples:
+//
+//	<@USH186XSP> export
+//	<@USH186XSP> export html
+//	<@USH186XSP> export text
+type ExportMsg struct {
+	Format string
+	raw    *slack.MessageEvent
+}
+
+func (m ExportMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m ExportMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m ExportMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m ExportMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseExportMsg(m *slack.MessageEvent) (*ExportMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:export(?: (markdown|md|html|text|txt))?) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &ExportMsg{
+		Format: parseTranscriptFormat(matches[1]),
+		raw:    m,
+	}, true
+}
+
+func (msg ExportMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("export requested:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("export attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	t := transcript.New(session, items)
+
+	t.Link, err = api.GetPermalink(&slack.PermalinkParameters{
+		Channel: msg.ChannelID(),
+		Ts:      session.ThreadTimestamp,
+	})
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	var b strings.Builder
+	if err := t.Render(&b, msg.Format); err != nil {
+		log.Println("unable to render transcript:", err)
+		threadReply(rtm, msg, "Hmm, I couldn't write that one down. Try another format?")
+		return
+	}
+
+	_, err = api.UploadFile(slack.FileUploadParameters{
+		Content:         b.String(),
+		Filename:        "journey-" + session.ThreadTimestamp + "." + transcript.Extension(msg.Format),
+		Title:           t.Title,
+		Channels:        []string{msg.ChannelID()},
+		ThreadTimestamp: msg.ThreadTimestamp(),
+	})
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+}
+
+// accepts short names for formats, ex. "md" for markdown
+func parseTranscriptFormat(format string) string {
+	switch strings.ToLower(format) {
+	case "html":
+		return transcript.HTML
+	case "text", "txt":
+		return transcript.Text
+	}
+
+	return transcript.Markdown
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// FORKING JOURNEYS //
+
+// how much of the story so far is given to the engine when starting a fork.
+// the start of long stories is cut off.
+const maxForkPromptLength = 3000
+
+// when someone wants to explore a different path from an existing journey.
+// without a turn, the fork starts from the latest one. examples:
+//
+//	<@USH186XSP> fork
+//	<@USH186XSP> fork 3
+//	<@USH186XSP> fork from turn 3
+type ForkMsg struct {
+	AuthorID string
+	// -1 if not given
+	Turn int
+	raw  *slack.MessageEvent
+}
+
+func (m ForkMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m ForkMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m ForkMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m ForkMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseForkMsg(m *slack.MessageEvent) (*ForkMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:fork(?: (?:from )?(?:turn )?([0-9]+))?) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	turn := -1
+	if matches[1] != "" {
+		var err error
+		turn, err = strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	return &ForkMsg{
+		AuthorID: m.User,
+		Turn:     turn,
+		raw:      m,
+	}, true
+}
+
+func (msg ForkMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("fork requested:", msg)
+
+	parent, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("fork attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	if !parent.Paid {
+		threadReply(rtm, msg, "We haven't even started yet! There's nothing to fork.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	items, err := dbc.GetStoryItems(parent)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	turns := db.StoryTurns(items)
+	if len(turns) == 0 {
+		threadReply(rtm, msg, "I can't remember enough of this journey to fork it. It might be from before I learned how.")
+		return
+	}
+
+	turn := msg.Turn
+	if turn == -1 {
+		turn = len(turns) - 1
+	}
+
+	if turn >= len(turns) {
+		threadReply(rtm, msg, "We've only made it to turn "+strconv.Itoa(len(turns)-1)+" so far!")
+		return
+	}
+
+	parentLink, err := api.GetPermalink(&slack.PermalinkParameters{
+		Channel: msg.ChannelID(),
+		Ts:      parent.ThreadTimestamp,
+	})
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !allowPlay(rtm, msg, dbc, author, false) {
+		return
+	}
+
+	_, forkTs, err := api.PostMessage(
+		msg.ChannelID(),
+		slack.MsgOptionText("_<@"+author.ID+"> wonders what would have happened if <"+parentLink+"|this journey> took a different path at turn "+strconv.Itoa(turn)+"..._", false),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	fork, err := dbc.CreateSession(db.Session{
+		ThreadTimestamp:  forkTs,
+		ChannelID:        msg.ChannelID(),
+		Creator:          author,
+		CostGP:           config().CostToPlay,
+		Prompt:           storySoFar(turns[:turn+1]),
+		ParentAirtableID: parent.AirtableID,
+		ParentTurn:       turn,
+		// whoever kept the original out of the storybook keeps its story
+		// so far out too
+		Unlisted:   parent.Unlisted || !isPublicChannel(api, msg.ChannelID()),
+		UnlistedBy: parent.UnlistedBy,
+	})
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	log.Println("FORK CREATED", fork)
+
+	if err := dbc.CreateStoryItem(parent, "Metadata", &author, "forked turn "+strconv.Itoa(turn)+" into "+forkTs); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	forkLink, err := api.GetPermalink(&slack.PermalinkParameters{
+		Channel: fork.ChannelID,
+		Ts:      fork.ThreadTimestamp,
+	})
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	threadReply(rtm, msg, "A new path opens up! Follow it <"+forkLink+"|over here>.")
+
+	askForPayment(rtm, sessionThread{fork}, fork)
+}
+
+// storySoFar pieces the story back together from its turns so it can be
+// given to the engine as a prompt
+func storySoFar(turns []db.Turn) string {
+	paragraphs := []string{}
+	for _, turn := range turns {
+		for _, input := range turn.Inputs {
+			text := input.EngineInput
+			if text == "" {
+				text = input.Value
+			}
+
+			paragraphs = append(paragraphs, "> "+strings.TrimSpace(text))
+		}
+
+		paragraphs = append(paragraphs, strings.TrimSpace(turn.Output.Value))
+	}
+
+	story := strings.Join(paragraphs, "\n\n")
+	if len(story) <= maxForkPromptLength {
+		return story
+	}
+
+	// cut off the start, but not in the middle of a paragraph if we can
+	// help it
+	cut := len(story) - maxForkPromptLength
+	for cut < len(story) && !utf8.RuneStart(story[cut]) {
+		cut++
+	}
+
+	story = story[cut:]
+	if i := strings.Index(story, "\n\n"); i != -1 {
+		story = story[i+2:]
+	}
+
+	return story
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// PAUSING & ENDING JOURNEYS //
+
+// sent to the engine as story text to lead it into an epilogue
+const epiloguePrompt = "And so our journey came to an end. In the years that followed,"
+
+// when participants pause, resume or finish a journey. examples:
+//
+//	<@USH186XSP> pause
+//	<@USH186XSP> resume
+//	<@USH186XSP> the end
+type JourneyStatusMsg struct {
+	AuthorID string
+	Status   string
+	raw      *slack.MessageEvent
+}
+
+func (m JourneyStatusMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m JourneyStatusMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m JourneyStatusMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m JourneyStatusMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseJourneyStatusMsg(m *slack.MessageEvent) (*JourneyStatusMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:(pause|resume|the end))[.!]* *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	var status string
+	switch strings.ToLower(matches[1]) {
+	case "pause":
+		status = db.StatusPaused
+	case "resume":
+		status = db.StatusActive
+	case "the end":
+		status = db.StatusEnded
+	}
+
+	return &JourneyStatusMsg{
+		AuthorID: m.User,
+		Status:   status,
+		raw:      m,
+	}, true
+}
+
+func (msg JourneyStatusMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("journey status change requested:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("status change attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.IsParticipant(author) {
+		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to embark on.")
+		return
+	}
+
+	if !session.Paid {
+		threadReply(rtm, msg, "We haven't even started yet!")
+		return
+	}
+
+	if session.Status == db.StatusEnded {
+		threadReply(rtm, msg, "This journey is already over. Mention me in the channel with a new prompt to start another!")
+		return
+	}
+
+	if session.Status == msg.Status {
+		threadReply(rtm, msg, "Already done!")
+		return
+	}
+
+	switch msg.Status {
+	case db.StatusPaused:
+		session, err = dbc.SetSessionStatus(session, db.StatusPaused)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, "paused the journey"); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		threadReply(rtm, msg, "_sets up camp for the night..._ We'll wait here until someone says `@dungeon resume`.")
+	case db.StatusActive:
+		session, err = dbc.SetSessionStatus(session, db.StatusActive)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, "resumed the journey"); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		threadReply(rtm, msg, "_packs up camp..._ Onwards!")
+		announceTurn(rtm, msg, session)
+	case db.StatusEnded:
+		if !session.Creator.Eq(author) {
+			threadReply(rtm, msg, "Only "+session.Creator.Name+" can bring this journey to an end.")
+			return
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, "ended the journey"); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		endJourney(api, rtm, msg, dbc, aidungeonc, session)
+	}
+}
+
+// endJourney closes out a journey with a generated epilogue
+func endJourney(api *slack.Client, rtm *slack.RTM, thread Thread, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session) {
+	session, err := dbc.SetSessionStatus(session, db.StatusEnded)
+	if err != nil {
+		handleDBError(rtm, thread, err)
+		return
+	}
+
+	typing(rtm, thread)
+
+	epilogue, err := aidungeonc.Input(session.SessionID, epiloguePrompt)
+	if err != nil {
+		handleDungeonError(rtm, thread, err)
+		return
+	}
+
+	// a journey still ends without an epilogue we can share
+	if epilogue, ok := moderateOutput(rtm, thread, dbc, aidungeonc, session, epilogue); ok {
+		epilogue = epiloguePrompt + " " + strings.TrimSpace(epilogue)
+
+		epilogueTs, err := threadPost(api, thread, epilogue)
+		if err != nil {
+			handleSlackError(rtm, thread, err)
+			return
+		}
+
+		if err := dbc.CreateStoryItem(session, "Epilogue", nil, epilogue); err != nil {
+			handleDBError(rtm, thread, err)
+			return
+		}
+
+		log.Println("ended session", session.ThreadTimestamp, "with epilogue in", epilogueTs)
+	}
+
+	threadReply(rtm, thread, "*~ THE END ~*\n_thank you for journeying with me._")
+
+	checkAchievements(rtm, thread, dbc, session, session.Participants())
+}
+
+// checkPlayable lets players know when a journey can't be played right now.
+// false if it can't.
+func checkPlayable(rtm *slack.RTM, msg Thread, session db.Session) bool {
+	switch session.Status {
+	case db.StatusPaused:
+		threadReply(rtm, msg, "We're resting at camp right now. Say `@dungeon resume` to pick the journey back up.")
+		return false
+	case db.StatusEnded:
+		threadReply(rtm, msg, "This journey has come to an end. Mention me in the channel with a new prompt to start another!")
+		return false
+	}
+
+	return true
+}
+
+// pauseIdleSessions pauses journeys nobody has played in a while
+func pauseIdleSessions(rtm *slack.RTM, dbc *db.DB) {
+	if config().AutoPauseDays == 0 {
+		return
+	}
+
+	sessions, err := dbc.ListIdleSessions(time.Now().AddDate(0, 0, -config().AutoPauseDays))
+	if err != nil {
+		log.Println("unable to list idle sessions:", err)
+		return
+	}
+
+	for _, session := range sessions {
+		log.Println("pausing idle session", session.ThreadTimestamp)
+
+		session, err = dbc.SetSessionStatus(session, db.StatusPaused)
+		if err != nil {
+			log.Println("unable to pause idle session:", err)
+			continue
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", nil, "paused after "+strconv.Itoa(config().AutoPauseDays)+" days without play"); err != nil {
+			log.Println("unable to record pause:", err)
+		}
+
+		// sessions from before we tracked channels can't be replied to
+		if session.ChannelID == "" {
+			continue
+		}
+
+		threadReply(rtm, sessionThread{session}, "_it's been quiet for a while, so i've set up camp._ Say `@dungeon resume` whenever you're ready to continue.")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./db"
+	"./ratelimit"
+)
+
+// RATE LIMITS //
+
+// everyone's buckets, shared by every kind of limit
+var limiter = ratelimit.New()
+
+// allowPlay keeps any one player, channel or everyone together from playing
+// faster than the engine (and everyone else in the channel) can keep up
+// with. turns also count against the player's daily quota. false if they
+// have to wait, in which case they've been told for how long.
+func allowPlay(rtm *slack.RTM, thread Thread, dbc *db.DB, author db.SlackUser, turn bool) bool {
+	c := config()
+	now := time.Now()
+
+	if turn && c.DailyTurnQuota > 0 {
+		today := now.UTC().Truncate(24 * time.Hour)
+
+		played, err := dbc.CountTurnsSince(author.ID, today)
+		if err != nil {
+			// not worth stopping anyone from playing over
+			log.Println("unable to check daily turn quota:", err)
+		} else if played >= c.DailyTurnQuota {
+			threadReply(rtm, thread, "That's all "+plural(c.DailyTurnQuota, "turn")+" you get today, my friend! "+
+				"You can play again in "+waitString(today.Add(24*time.Hour).Sub(now))+" (at midnight UTC).")
+			return false
+		}
+	}
+
+	userLimit := ratelimit.Limit{Key: "user:" + author.ID, Rate: c.UserRateLimit}
+	channelLimit := ratelimit.Limit{Key: "channel:" + thread.ChannelID(), Rate: c.ChannelRateLimit}
+	globalLimit := ratelimit.Limit{Key: "global", Rate: c.GlobalRateLimit}
+
+	wait, blocking := limiter.Allow(now, userLimit, channelLimit, globalLimit)
+	if blocking == nil {
+		return true
+	}
+
+	log.Println("rate limited", author.ToString(), "by", blocking.Key, "for", wait)
+
+	switch blocking.Key {
+	case userLimit.Key:
+		threadReply(rtm, thread, "Whoa there, slow down! You can go again in "+waitString(wait)+".")
+	case channelLimit.Key:
+		threadReply(rtm, thread, "This channel's been busy! Give it "+waitString(wait)+" and try again.")
+	default:
+		threadReply(rtm, thread, "I'm thinking about a lot of journeys right now! Try again in "+waitString(wait)+".")
+	}
+
+	return false
+}
+
+// ex. "12 seconds", "4 minutes", "3 hours", always rounded up
+func waitString(d time.Duration) string {
+	switch {
+	case d <= time.Minute:
+		return plural(int((d+time.Second-1)/time.Second), "second")
+	case d <= time.Hour:
+		return plural(int((d+time.Minute-1)/time.Minute), "minute")
+	default:
+		return plural(int((d+time.Hour-1)/time.Hour), "hour")
+	}
+}
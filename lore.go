@@ -0,0 +1,482 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./ratelimit"
+	"./story"
+)
+
+// WORLD LORE //
+
+// how much lore a journey draws on, so it doesn't crowd out the story
+const maxLoreLength = 1000
+
+// how many of a journey's latest outputs are checked (along with its prompt)
+// for the characters and places it should draw on
+const loreContextTurns = 5
+
+// how long a channel's lore is kept in memory, so checking every turn
+// against it doesn't mean a trip to the store every turn
+const loreCacheTTL = 5 * time.Minute
+
+// how often a journey can propose new lore, so a story full of names doesn't
+// flood its thread or the proposals
+var loreProposalRate = ratelimit.Rate{Count: 1, Per: 15 * time.Minute}
+
+type cachedLore struct {
+	lore      []db.Lore
+	fetchedAt time.Time
+}
+
+var (
+	loreCacheMu sync.Mutex
+	loreCache   = map[string]cachedLore{}
+)
+
+// the names of the lore each journey's context was last pinned with, by
+// thread
+var (
+	pinnedLoreMu sync.Mutex
+	pinnedLore   = map[string]map[string]bool{}
+)
+
+// what stories introduce places as, ex. "a village called Millbrook"
+var placeNouns = map[string]bool{
+	"city": true, "town": true, "village": true, "kingdom": true,
+	"castle": true, "forest": true, "tavern": true, "inn": true,
+	"island": true, "mountain": true, "river": true, "lake": true,
+	"land": true, "realm": true, "planet": true, "station": true,
+	"temple": true, "cave": true, "valley": true, "desert": true,
+}
+
+// when someone wants to see or shape the world a channel's journeys share.
+// admins' additions are part of the world right away, everyone else's are
+// proposed for an admin to approve. examples:
+//
+//	<@USH186XSP> lore
+//	<@USH186XSP> lore proposals
+//	<@USH186XSP> lore add Larion: a kingdom ruled by the ageing King George VII.
+//	<@USH186XSP> lore add npc Vex: a red dragon who hoards clocks.
+//	<@USH186XSP> lore add place The Sunken Library: a flooded archive under Larion.
+//	<@USH186XSP> lore approve Vex
+//	<@USH186XSP> lore reject Vex
+//	<@USH186XSP> lore remove Vex
+type LoreMsg struct {
+	AuthorID string
+	// "list", "proposals", "add", "approve", "reject" or "remove"
+	Command     string
+	Kind        string
+	Name        string
+	Description string
+	raw         *slack.MessageEvent
+}
+
+func (m LoreMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m LoreMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m LoreMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m LoreMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseLoreMsg(m *slack.MessageEvent) (*LoreMsg, bool) {
+	regex := regexp.MustCompile(`(?s)^<@` + SelfID + `> (?i:lore)(?: (?i:(proposals)|(add) (?:(npc|place|lore) )?([^:<>]{1,60}): (.+)|(approve|reject|remove) ([^<>]{1,60})))? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	command := strings.ToLower(matches[1] + matches[2] + matches[6])
+	if command == "" {
+		command = "list"
+	}
+
+	kind := db.LoreKindLore
+	switch strings.ToLower(matches[3]) {
+	case "npc":
+		kind = db.LoreKindNPC
+	case "place":
+		kind = db.LoreKindPlace
+	}
+
+	return &LoreMsg{
+		AuthorID:    m.User,
+		Command:     command,
+		Kind:        kind,
+		Name:        strings.TrimSpace(matches[4] + matches[7]),
+		Description: strings.TrimSpace(matches[5]),
+		raw:         m,
+	}, true
+}
+
+func (msg LoreMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("lore command:", msg)
+
+	switch msg.Command {
+	case "list", "proposals":
+		status := db.LoreStatusApproved
+		if msg.Command == "proposals" {
+			status = db.LoreStatusProposed
+		}
+
+		lore, err := dbc.ListLore(msg.ChannelID(), status)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if len(lore) == 0 {
+			if msg.Command == "proposals" {
+				threadReply(rtm, msg, "Nobody's proposed any lore for this world. Add some with `@dungeon lore add <name>: <description>`.")
+			} else {
+				threadReply(rtm, msg, "The world of this channel is a blank page. Add to it with `@dungeon lore add <name>: <description>`.")
+			}
+
+			return
+		}
+
+		heading := "here's what i know about this world:"
+		if msg.Command == "proposals" {
+			heading = "here's the lore waiting on an admin:"
+		}
+
+		threadReply(rtm, msg, heading+"\n\n"+loreList(lore))
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	lore, err := dbc.ListLore(msg.ChannelID(), "")
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	existing, found := findLore(lore, msg.Name)
+
+	if msg.Command == "add" {
+		if found {
+			threadReply(rtm, msg, "This world already has a *"+existing.Name+"*!")
+			return
+		}
+
+		if !allowInput(rtm, msg, dbc, author, msg.Name+": "+msg.Description) {
+			return
+		}
+
+		status := db.LoreStatusProposed
+		if isAdmin(author.ID) {
+			status = db.LoreStatusApproved
+		}
+
+		_, err := dbc.CreateLore(db.Lore{
+			ChannelID:       msg.ChannelID(),
+			Kind:            msg.Kind,
+			Name:            msg.Name,
+			Description:     msg.Description,
+			Status:          status,
+			ProposedBy:      &author,
+			ThreadTimestamp: msg.raw.ThreadTimestamp,
+		})
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		forgetChannelLore(msg.ChannelID())
+
+		if status == db.LoreStatusApproved {
+			threadReply(rtm, msg, "*"+msg.Name+"* is now part of this world. Journeys started here will remember it.")
+		} else {
+			threadReply(rtm, msg, "I've proposed *"+msg.Name+"* for this world. An admin can make it official with `@dungeon lore approve "+msg.Name+"`.")
+		}
+
+		return
+	}
+
+	if !isAdmin(author.ID) {
+		threadReply(rtm, msg, "...sorry my friend, but only admins can shape this world.")
+		return
+	}
+
+	if !found {
+		threadReply(rtm, msg, "I don't know of any *"+msg.Name+"* in this world. `@dungeon lore` and `@dungeon lore proposals` show what I do know.")
+		return
+	}
+
+	switch msg.Command {
+	case "approve":
+		if existing.Status == db.LoreStatusApproved {
+			threadReply(rtm, msg, "*"+existing.Name+"* is already part of this world.")
+			return
+		}
+
+		if _, err := dbc.SetLoreStatus(existing, db.LoreStatusApproved); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		forgetChannelLore(msg.ChannelID())
+
+		threadReply(rtm, msg, "*"+existing.Name+"* is now part of this world. Journeys started here will remember it.")
+	case "reject", "remove":
+		if msg.Command == "reject" && existing.Status != db.LoreStatusProposed {
+			threadReply(rtm, msg, "*"+existing.Name+"* is already part of this world. Use `@dungeon lore remove "+existing.Name+"` to take it out.")
+			return
+		}
+
+		if err := dbc.DeleteLore(existing); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		forgetChannelLore(msg.ChannelID())
+
+		threadReply(rtm, msg, "*"+existing.Name+"* has faded from this world.")
+	}
+}
+
+// channelLore returns all of a channel's lore, approved or not, from memory
+// if it was fetched recently
+func channelLore(dbc *db.DB, channelID string) ([]db.Lore, error) {
+	loreCacheMu.Lock()
+	cached, ok := loreCache[channelID]
+	loreCacheMu.Unlock()
+
+	if ok && time.Since(cached.fetchedAt) < loreCacheTTL {
+		return cached.lore, nil
+	}
+
+	lore, err := dbc.ListLore(channelID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	loreCacheMu.Lock()
+	loreCache[channelID] = cachedLore{lore: lore, fetchedAt: time.Now()}
+	loreCacheMu.Unlock()
+
+	return lore, nil
+}
+
+// forgetChannelLore drops a channel's lore from memory once it's changed
+func forgetChannelLore(channelID string) {
+	loreCacheMu.Lock()
+	defer loreCacheMu.Unlock()
+
+	delete(loreCache, channelID)
+}
+
+func approvedLore(lore []db.Lore) []db.Lore {
+	approved := []db.Lore{}
+	for _, entry := range lore {
+		if entry.Status == db.LoreStatusApproved {
+			approved = append(approved, entry)
+		}
+	}
+
+	return approved
+}
+
+// recentStory is the text lore is matched against: the last few outputs of
+// the story
+func recentStory(items []db.StoryItem) string {
+	turns := db.StoryTurns(items)
+	if len(turns) > loreContextTurns {
+		turns = turns[len(turns)-loreContextTurns:]
+	}
+
+	return storyText(turns)
+}
+
+// drawOnLore re-pins a journey's context when the story brings up characters
+// or places from the channel's world that it isn't drawing on yet. this is a
+// nice-to-have, so problems are only logged.
+func drawOnLore(rtm *slack.RTM, thread Thread, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session, output string) {
+	lore, err := channelLore(dbc, session.ChannelID)
+	if err != nil {
+		log.Println("unable to list lore to draw on:", err)
+		return
+	}
+
+	pinnedLoreMu.Lock()
+	pinned := pinnedLore[session.ThreadTimestamp]
+	pinnedLoreMu.Unlock()
+
+	newlyMentioned := false
+	for _, entry := range relevantLore(approvedLore(lore), output) {
+		if !pinned[entry.Name] {
+			newlyMentioned = true
+			break
+		}
+	}
+
+	if !newlyMentioned {
+		return
+	}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		log.Println("unable to get story items to draw on lore:", err)
+		return
+	}
+
+	pinContext(rtm, thread, dbc, aidungeonc, session, activeMemories(items), recentStory(items))
+}
+
+// proposeLore proposes anyone or anything new the story introduced by name as
+// lore for the channel's world, at most once in a while for each journey.
+// this is a nice-to-have, so problems are only logged.
+func proposeLore(rtm *slack.RTM, thread Thread, dbc *db.DB, session db.Session, output string) {
+	introductions := story.Introductions(output)
+	if len(introductions) == 0 {
+		return
+	}
+
+	lore, err := channelLore(dbc, session.ChannelID)
+	if err != nil {
+		log.Println("unable to list lore to propose new lore:", err)
+		return
+	}
+
+	unknown := []story.Introduction{}
+	for _, introduction := range introductions {
+		if _, found := findLore(lore, introduction.Name); !found {
+			unknown = append(unknown, introduction)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return
+	}
+
+	limit := ratelimit.Limit{Key: "lore:" + session.ThreadTimestamp, Rate: loreProposalRate}
+	if _, blocking := limiter.Allow(time.Now(), limit); blocking != nil {
+		log.Println("not proposing lore from", session.ThreadTimestamp, "- proposed some recently")
+		return
+	}
+
+	defer forgetChannelLore(session.ChannelID)
+
+	proposed := []string{}
+	for _, introduction := range unknown {
+		kind := db.LoreKindNPC
+		if placeNouns[introduction.Noun] {
+			kind = db.LoreKindPlace
+		}
+
+		_, err := dbc.CreateLore(db.Lore{
+			ChannelID:       session.ChannelID,
+			Kind:            kind,
+			Name:            introduction.Name,
+			Description:     introduction.Sentence,
+			Status:          db.LoreStatusProposed,
+			ThreadTimestamp: session.ThreadTimestamp,
+		})
+		if err != nil {
+			log.Println("unable to propose lore:", err)
+			continue
+		}
+
+		proposed = append(proposed, "*"+introduction.Name+"*")
+	}
+
+	if len(proposed) == 0 {
+		return
+	}
+
+	threadReply(rtm, thread, "_:scroll: "+strings.Join(proposed, ", ")+" could become part of this world. An admin can make it official with `@dungeon lore approve <name>`._")
+}
+
+// relevantLore picks the lore a journey should draw on: general lore about
+// the world, plus any characters and places the text mentions
+func relevantLore(lore []db.Lore, text string) []db.Lore {
+	text = strings.ToLower(text)
+
+	relevant := []db.Lore{}
+	length := 0
+	for _, entry := range lore {
+		if entry.Kind != db.LoreKindLore && !mentionsAny(text, append([]string{entry.Name}, entry.Keywords...)) {
+			continue
+		}
+
+		// one long entry doesn't crowd out shorter ones after it
+		if length+len(loreFact(entry)) > maxLoreLength {
+			continue
+		}
+
+		length += len(loreFact(entry))
+		relevant = append(relevant, entry)
+	}
+
+	return relevant
+}
+
+func mentionsAny(text string, names []string) bool {
+	for _, name := range names {
+		regex := regexp.MustCompile(`\b` + regexp.QuoteMeta(strings.ToLower(name)) + `\b`)
+		if regex.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loreFact is how lore is put to the engine
+func loreFact(entry db.Lore) string {
+	if entry.Kind == db.LoreKindLore {
+		return punctuateFact(entry.Name + ": " + entry.Description)
+	}
+
+	return punctuateFact(entry.Name + " (" + entry.Kind + "): " + entry.Description)
+}
+
+func findLore(lore []db.Lore, name string) (db.Lore, bool) {
+	for _, entry := range lore {
+		if strings.EqualFold(entry.Name, name) {
+			return entry, true
+		}
+	}
+
+	return db.Lore{}, false
+}
+
+func loreList(lore []db.Lore) string {
+	lines := make([]string, len(lore))
+	for i, entry := range lore {
+		lines[i] = "• *" + entry.Name + "*"
+		if entry.Kind != db.LoreKindLore {
+			lines[i] += " (" + entry.Kind + ")"
+		}
+		lines[i] += ": " + entry.Description
+	}
+
+	return strings.Join(lines, "\n")
+}
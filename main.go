@@ -19,6 +19,17 @@ func main() {
 		log.Fatal("error loading .env file")
 	}
 
+	setConfig(loadConfig())
+
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
+	if err := loadModerator(); err != nil {
+		log.Fatal("error loading moderation word list:", err)
+	}
+
 	slackAuthToken := os.Getenv("SLACK_LEGACY_TOKEN")
 	aidungeonEmail := os.Getenv("AIDUNGEON_EMAIL")
 	aidungeonPassword := os.Getenv("AIDUNGEON_PASSWORD")
@@ -43,11 +54,21 @@ func main() {
 
 	log.Println("authenticated with airtable")
 
+	if err := loadBans(dbc); err != nil {
+		log.Fatal("error loading bans:", err)
+	}
+
+	if err := loadScenarioNames(dbc); err != nil {
+		log.Fatal("error loading scenarios:", err)
+	}
+
 	api := slack.New(slackAuthToken)
 
 	rtm := api.NewRTM()
 	go rtm.ManageConnection()
 
+	go watchSessions(api, rtm, dbc, aidungeonc)
+
 	for rawMsg := range rtm.IncomingEvents {
 		log.Println("event received:", rawMsg)
 
@@ -60,6 +81,11 @@ func main() {
 
 			log.Println("raw event", ev)
 
+			if isBanned(ev.User) {
+				log.Println("ignoring message from banned user", ev.User)
+				continue
+			}
+
 			msg := parseMessage(ev)
 			if msg == nil {
 				log.Println("unable to parse message event, ignoring...")
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// MEMORIES //
+
+// everything pinned is sent along with the story, so keep it from crowding
+// the story out
+const (
+	maxMemories     = 10
+	maxMemoryLength = 200
+)
+
+// the story item type memories are stored as
+const memoryItemType = "Memory"
+
+const memoriesListHint = "`@dungeon memories` shows what I'm remembering."
+
+// when a participant wants the engine to keep a fact in mind, stop keeping one
+// in mind or see what it's keeping in mind. forget takes the fact's number
+// from the memories list. examples:
+//
+//	<@USH186XSP> remember The dragon's name is Vex.
+//	<@USH186XSP> forget 2
+//	<@USH186XSP> memories
+type MemoryMsg struct {
+	AuthorID string
+	Command  string
+	Fact     string
+	// for forget, 1 is the first memory
+	Number int
+	raw    *slack.MessageEvent
+}
+
+func (m MemoryMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m MemoryMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m MemoryMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m MemoryMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseMemoryMsg(m *slack.MessageEvent) (*MemoryMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`(?s)^<@` + SelfID + `> (?:(?i:(remember)) (.+)|(?i:(forget)) #?([0-9]+) *|(?i:(memories)) *)$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	number := 0
+	if matches[4] != "" {
+		var err error
+		number, err = strconv.Atoi(matches[4])
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	return &MemoryMsg{
+		AuthorID: m.User,
+		Command:  strings.ToLower(matches[1] + matches[3] + matches[5]),
+		Fact:     strings.TrimSpace(matches[2]),
+		Number:   number,
+		raw:      m,
+	}, true
+}
+
+func (msg MemoryMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("memory command:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("memory command attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	memories := activeMemories(items)
+
+	// anyone can see what's pinned, only participants can change it
+	if msg.Command == "memories" {
+		if len(memories) == 0 {
+			threadReply(rtm, msg, "I'm not remembering anything in particular. Tell me something important with `@dungeon remember <fact>`.")
+			return
+		}
+
+		threadReply(rtm, msg, "here's what i'm keeping in mind:\n\n"+numberedList(memories))
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.IsParticipant(author) {
+		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to embark on.")
+		return
+	}
+
+	if !session.Paid {
+		threadReply(rtm, msg, "We haven't even started yet!")
+		return
+	}
+
+	switch msg.Command {
+	case "remember":
+		if len(memories) >= maxMemories {
+			threadReply(rtm, msg, "My head's full! Forget something first. "+memoriesListHint)
+			return
+		}
+
+		if len(msg.Fact) > maxMemoryLength {
+			threadReply(rtm, msg, "That's a lot to remember! Can you keep it under "+strconv.Itoa(maxMemoryLength)+" characters?")
+			return
+		}
+
+		if !allowInput(rtm, msg, dbc, author, msg.Fact) {
+			return
+		}
+
+		if err := dbc.CreateStoryItem(session, memoryItemType, &author, msg.Fact); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		memories = append(memories, db.StoryItem{Type: memoryItemType, Author: &author, Value: msg.Fact})
+	case "forget":
+		if msg.Number < 1 || msg.Number > len(memories) {
+			threadReply(rtm, msg, "I don't have a memory #"+strconv.Itoa(msg.Number)+". "+memoriesListHint)
+			return
+		}
+
+		forgotten := memories[msg.Number-1]
+		if err := dbc.MarkStoryItemSuperseded(forgotten); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, "forgot "+forgotten.Value); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		memories = append(memories[:msg.Number-1], memories[msg.Number:]...)
+	}
+
+	typing(rtm, msg)
+
+	if !pinContext(rtm, msg, dbc, aidungeonc, session, memories, recentStory(items)) {
+		return
+	}
+
+	if msg.Command == "remember" {
+		threadReply(rtm, msg, "Got it, I'll keep that in mind. "+memoriesListHint)
+	} else {
+		threadReply(rtm, msg, "Forgotten! It's as if it never crossed my mind.")
+	}
+}
+
+// activeMemories returns the facts participants have asked us to remember
+// and haven't forgotten, oldest first
+func activeMemories(items []db.StoryItem) []db.StoryItem {
+	memories := []db.StoryItem{}
+	for _, item := range items {
+		if item.Type == memoryItemType && !item.Superseded {
+			memories = append(memories, item)
+		}
+	}
+
+	return memories
+}
+
+// pinContext has the engine keep everything it should about a session in
+// mind: the facts participants asked us to remember, the world lore the
+// journey draws on (going by its prompt and recent story) and, if turned on,
+// a summary of each character sheet. returns false if something went wrong.
+func pinContext(rtm *slack.RTM, thread Thread, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session, memories []db.StoryItem, recent string) bool {
+	lore, err := channelLore(dbc, session.ChannelID)
+	if err != nil {
+		handleDBError(rtm, thread, err)
+		return false
+	}
+
+	var sheets []db.CharacterSheet
+	if config().SheetsInContext {
+		sheets, err = dbc.GetCharacterSheets(session)
+		if err != nil {
+			handleDBError(rtm, thread, err)
+			return false
+		}
+	}
+
+	relevant := relevantLore(approvedLore(lore), session.Prompt+"\n\n"+recent)
+
+	pinned := pinnedContext(memories, relevant, sheets)
+	if err := aidungeonc.Remember(session.SessionID, pinned); err != nil {
+		handleDungeonError(rtm, thread, err)
+		return false
+	}
+
+	names := map[string]bool{}
+	for _, entry := range relevant {
+		names[entry.Name] = true
+	}
+
+	pinnedLoreMu.Lock()
+	pinnedLore[session.ThreadTimestamp] = names
+	pinnedLoreMu.Unlock()
+
+	return true
+}
+
+// pinnedContext is the text we ask the engine to keep in mind
+func pinnedContext(memories []db.StoryItem, lore []db.Lore, sheets []db.CharacterSheet) string {
+	facts := []string{}
+	for _, entry := range lore {
+		facts = append(facts, loreFact(entry))
+	}
+
+	for _, memory := range memories {
+		facts = append(facts, punctuateFact(memory.Value))
+	}
+
+	for _, sheet := range sheets {
+		facts = append(facts, sheetSummary(sheet))
+	}
+
+	return strings.Join(facts, " ")
+}
+
+func punctuateFact(fact string) string {
+	fact = strings.TrimSpace(fact)
+	if strings.HasSuffix(fact, ".") || strings.HasSuffix(fact, "!") || strings.HasSuffix(fact, "?") {
+		return fact
+	}
+
+	return fact + "."
+}
+
+func numberedList(items []db.StoryItem) string {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = strconv.Itoa(i+1) + ". " + item.Value
+	}
+
+	return strings.Join(lines, "\n")
+}
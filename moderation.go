@@ -0,0 +1,162 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./moderation"
+)
+
+// MODERATION //
+
+// what prompts, inputs and outputs are checked against before they're
+// posted, and how closely each channel is moderated. set up from config.
+type moderationSettings struct {
+	classifier          moderation.Classifier
+	defaultStrictness   moderation.Strictness
+	channelStrictnesses map[string]moderation.Strictness
+}
+
+var (
+	moderationMu sync.RWMutex
+	moderator    = moderationSettings{classifier: moderation.Classifiers{}}
+)
+
+// loadModerator sets up moderation from config. a missing word list just
+// means nothing is blocked.
+func loadModerator() error {
+	c := config()
+
+	settings := moderationSettings{
+		channelStrictnesses: map[string]moderation.Strictness{},
+	}
+
+	var err error
+	settings.defaultStrictness, err = moderation.ParseStrictness(c.ModerationStrictness)
+	if err != nil {
+		log.Println("invalid moderation strictness, moderating leniently:", err)
+		settings.defaultStrictness = moderation.Lenient
+	}
+
+	for channelID, name := range c.ModerationChannels {
+		strictness, err := moderation.ParseStrictness(name)
+		if err != nil {
+			log.Println("invalid moderation strictness for", channelID, "- ignoring:", err)
+			continue
+		}
+
+		settings.channelStrictnesses[channelID] = strictness
+	}
+
+	list, err := moderation.LoadWordList(c.ModerationList)
+	if os.IsNotExist(err) {
+		log.Println("no moderation word list at", c.ModerationList, "- nothing will be blocked")
+		settings.classifier = moderation.Classifiers{}
+	} else if err != nil {
+		return err
+	} else {
+		log.Println("loaded", list.Len(), "moderation rules from", c.ModerationList)
+		settings.classifier = moderation.Classifiers{list}
+	}
+
+	moderationMu.Lock()
+	defer moderationMu.Unlock()
+
+	moderator = settings
+
+	return nil
+}
+
+func moderationFor(channelID string) (moderation.Classifier, moderation.Strictness) {
+	moderationMu.RLock()
+	defer moderationMu.RUnlock()
+
+	if strictness, ok := moderator.channelStrictnesses[channelID]; ok {
+		return moderator.classifier, strictness
+	}
+
+	return moderator.classifier, moderator.defaultStrictness
+}
+
+// moderate checks text about to be used in a thread, keeping a record of
+// anything blocked. stage is what the text is, ex. "prompt". true if it's
+// fine to use.
+func moderate(dbc *db.DB, thread Thread, stage string, author *db.SlackUser, text string) bool {
+	classifier, strictness := moderationFor(thread.ChannelID())
+
+	verdict, err := classifier.Classify(text, strictness)
+	if err != nil {
+		// a broken classifier shouldn't stop everyone from playing
+		log.Println("unable to moderate", stage, "-", err)
+		return true
+	}
+
+	if !verdict.Blocked {
+		return true
+	}
+
+	log.Println("blocked", stage, "in", thread.ThreadTimestamp(), "-", verdict.Reason)
+
+	_, err = dbc.CreateAuditRecord(db.AuditRecord{
+		Action:          "blocked " + stage,
+		Actor:           author,
+		ChannelID:       thread.ChannelID(),
+		ThreadTimestamp: thread.ThreadTimestamp(),
+		Details:         verdict.Reason + "\n\n" + text,
+	})
+	if err != nil {
+		log.Println("unable to record blocked", stage, "-", err)
+	}
+
+	return false
+}
+
+// allowPrompt politely turns down prompts we can't play here. true if the
+// prompt is fine.
+func allowPrompt(rtm *slack.RTM, thread Thread, dbc *db.DB, author db.SlackUser, prompt string) bool {
+	if moderate(dbc, thread, "prompt", &author, prompt) {
+		return true
+	}
+
+	threadReply(rtm, thread, "Sorry my friend, but that's not a journey I can take you on here. Try another prompt?")
+	return false
+}
+
+// allowInput politely turns down inputs we can't play here. true if the
+// input is fine.
+func allowInput(rtm *slack.RTM, thread Thread, dbc *db.DB, author db.SlackUser, input string) bool {
+	if moderate(dbc, thread, "input", &author, input) {
+		return true
+	}
+
+	threadReply(rtm, thread, "Sorry my friend, but I can't take the story there. Try something else?")
+	return false
+}
+
+// moderateOutput checks what the engine came up with before it's posted,
+// giving it one more try if it's something we can't share. returns the
+// output to post, or false if there isn't one, in which case players have
+// already been told.
+func moderateOutput(rtm *slack.RTM, thread Thread, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session, output string) (string, bool) {
+	if moderate(dbc, thread, "output", nil, output) {
+		return output, true
+	}
+
+	retried, err := aidungeonc.Retry(session.SessionID)
+	if err != nil {
+		handleDungeonError(rtm, thread, err)
+		return "", false
+	}
+
+	if moderate(dbc, thread, "output", nil, retried) {
+		return retried, true
+	}
+
+	threadReply(rtm, thread, "_the story took a turn I can't share here, so I've left it out._")
+	return "", false
+}
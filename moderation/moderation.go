@@ -0,0 +1,169 @@
+// Checks text for content that shouldn't be posted in the workspace, ex.
+// NSFW or abusive prompts
+package moderation
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// How closely a channel is moderated
+type Strictness int
+
+const (
+	// nothing is blocked
+	Off Strictness = iota
+	// only the worst content is blocked
+	Lenient
+	// anything questionable is blocked
+	Strict
+)
+
+var strictnessNames = map[string]Strictness{
+	"off":     Off,
+	"lenient": Lenient,
+	"strict":  Strict,
+}
+
+// ParseStrictness reads "off", "lenient" or "strict"
+func ParseStrictness(name string) (Strictness, error) {
+	strictness, ok := strictnessNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Off, errors.New("unknown strictness: " + name)
+	}
+
+	return strictness, nil
+}
+
+// What a classifier made of some text
+type Verdict struct {
+	Blocked bool
+	// why it was blocked, for the audit log. not meant to be shown to
+	// whoever wrote it.
+	Reason string
+}
+
+// Anything that can decide whether text is fit to post. only the word list
+// exists for now, but hosted classifiers can slot in alongside it.
+type Classifier interface {
+	Classify(text string, strictness Strictness) (Verdict, error)
+}
+
+// Classifiers runs several classifiers in order, blocking text if any of them
+// does
+type Classifiers []Classifier
+
+func (cs Classifiers) Classify(text string, strictness Strictness) (Verdict, error) {
+	for _, c := range cs {
+		verdict, err := c.Classify(text, strictness)
+		if err != nil || verdict.Blocked {
+			return verdict, err
+		}
+	}
+
+	return Verdict{}, nil
+}
+
+type rule struct {
+	source  string
+	pattern *regexp.Regexp
+	// the least strict a channel can be for the rule to apply
+	strictness Strictness
+}
+
+// A local list of blocked words and patterns
+type WordList struct {
+	rules []rule
+}
+
+// LoadWordList reads a word list from a file. see ParseWordList for the
+// format.
+func LoadWordList(path string) (*WordList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseWordList(f)
+}
+
+// ParseWordList reads a word list with one entry per line. entries are words
+// or phrases, matched as whole words regardless of case, or regular
+// expressions between slashes. entries starting with "strict " only apply to
+// strictly moderated channels. blank lines and lines starting with # are
+// ignored, ex.
+//
+//	# blocked everywhere moderation is on
+//	some slur
+//	/k+i+l+ y+o+u+r+s+e+l+f+/
+//	# only blocked in strict channels
+//	strict damn
+func ParseWordList(r io.Reader) (*WordList, error) {
+	list := &WordList{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		strictness := Lenient
+		if strings.HasPrefix(line, "strict ") {
+			strictness = Strict
+			line = strings.TrimSpace(strings.TrimPrefix(line, "strict "))
+		}
+
+		var expr string
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			expr = line[1 : len(line)-1]
+		} else {
+			expr = `\b` + regexp.QuoteMeta(line) + `\b`
+		}
+
+		pattern, err := regexp.Compile(`(?i)` + expr)
+		if err != nil {
+			return nil, errors.New("invalid word list entry " + line + ": " + err.Error())
+		}
+
+		list.rules = append(list.rules, rule{
+			source:     line,
+			pattern:    pattern,
+			strictness: strictness,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Len is how many entries the list has
+func (l *WordList) Len() int {
+	return len(l.rules)
+}
+
+func (l *WordList) Classify(text string, strictness Strictness) (Verdict, error) {
+	if strictness == Off {
+		return Verdict{}, nil
+	}
+
+	for _, r := range l.rules {
+		if r.strictness > strictness {
+			continue
+		}
+
+		if r.pattern.MatchString(text) {
+			return Verdict{Blocked: true, Reason: `matched "` + r.source + `"`}, nil
+		}
+	}
+
+	return Verdict{}, nil
+}
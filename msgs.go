@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"regexp"
 	"strconv"
@@ -12,6 +11,7 @@ import (
 
 	"./aidungeon"
 	"./db"
+	"./story"
 )
 
 // CONSTANTS //
@@ -19,7 +19,6 @@ import (
 const SelfID = "USH186XSP"
 const BankerID = "UH50T81A6"
 const PlayDungeonChannelID = "CSHEL6LP5"
-const CostToPlay = 5 // in GP
 const ScenarioIdeas = `here are a few scenario ideas:
 
 • You are King George VII, a noble living in the kingdom of Larion. You have a pouch of gold and a small dagger. You are awakened by one of your servants who tells you that your keep is under attack. You look out the window and see an army of orcs marching towards your capital. They are led by a large orc named
@@ -32,11 +31,18 @@ const ScenarioIdeas = `here are a few scenario ideas:
 
 // HELPERS //
 
-func typing(rtm *slack.RTM, msg Msg) {
+// Anywhere we can reply to. Every Msg is one, but background jobs can reply
+// in a session's thread without a message to respond to.
+type Thread interface {
+	ChannelID() string
+	ThreadTimestamp() string
+}
+
+func typing(rtm *slack.RTM, msg Thread) {
 	rtm.SendMessage(rtm.NewTypingMessage(msg.ChannelID()))
 }
 
-func threadReply(rtm *slack.RTM, msg Msg, text string) {
+func threadReply(rtm *slack.RTM, msg Thread, text string) {
 	rtm.SendMessage(rtm.NewOutgoingMessage(
 		text,
 		msg.ChannelID(),
@@ -44,17 +50,30 @@ func threadReply(rtm *slack.RTM, msg Msg, text string) {
 	))
 }
 
-func handleSlackError(rtm *slack.RTM, msg Msg, err error) {
+// threadPost is threadReply for messages we need to refer back to later, ex.
+// to edit them. returns the posted message's timestamp.
+func threadPost(api *slack.Client, msg Thread, text string) (string, error) {
+	_, ts, err := api.PostMessage(
+		msg.ChannelID(),
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionTS(msg.ThreadTimestamp()),
+		slack.MsgOptionAsUser(true),
+	)
+
+	return ts, err
+}
+
+func handleSlackError(rtm *slack.RTM, msg Thread, err error) {
 	log.Println("slack api error:", err)
 	threadReply(rtm, msg, "Sorry, I'm having trouble connecting to Slack. Try again? (slack error)")
 }
 
-func handleDBError(rtm *slack.RTM, msg Msg, err error) {
+func handleDBError(rtm *slack.RTM, msg Thread, err error) {
 	log.Println("airtable api error:", err)
 	threadReply(rtm, msg, "Gosh, I'm having trouble remembering things right now. Sorry about that. Try again in a bit? (db error)")
 }
 
-func handleDungeonError(rtm *slack.RTM, msg Msg, err error) {
+func handleDungeonError(rtm *slack.RTM, msg Thread, err error) {
 	log.Println("ai dungeon api error:", err)
 	threadReply(rtm, msg, "Gosh, I'm having trouble thinking about our journey right now. Sorry about that. Try again in a bit? (backend error)")
 }
@@ -174,13 +193,23 @@ func (msg StartJourneyMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB,
 		return
 	}
 
-	session, err := dbc.CreateSession(
-		msg.Timestamp(),
-		creator,
-		companions,
-		CostToPlay,
-		msg.Prompt,
-	)
+	if !allowPrompt(rtm, msg, dbc, creator, msg.Prompt) {
+		return
+	}
+
+	if !allowPlay(rtm, msg, dbc, creator, false) {
+		return
+	}
+
+	session, err := dbc.CreateSession(db.Session{
+		ThreadTimestamp: msg.Timestamp(),
+		ChannelID:       msg.ChannelID(),
+		Creator:         creator,
+		Companions:      companions,
+		CostGP:          config().CostToPlay,
+		Prompt:          msg.Prompt,
+		Unlisted:        !isPublicChannel(api, msg.ChannelID()),
+	})
 	if err != nil {
 		handleDBError(rtm, msg, err)
 		return
@@ -188,13 +217,18 @@ func (msg StartJourneyMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB,
 
 	log.Println("SESSION CREATED", session)
 
-	threadReply(rtm, msg, "_groggily wakes up..._")
+	askForPayment(rtm, msg, session)
+}
+
+// askForPayment lets the creator of a new session know what it costs to start
+func askForPayment(rtm *slack.RTM, thread Thread, session db.Session) {
+	threadReply(rtm, thread, "_groggily wakes up..._")
 
 	time.Sleep(time.Second / 2)
-	typing(rtm, msg)
+	typing(rtm, thread)
 	time.Sleep(time.Second)
 
-	threadReply(rtm, msg, "Ugh... it's been a while. My bones are rough. My bones are weak. Load me up with "+strconv.Itoa(CostToPlay)+"GP and our journey together will make your week.")
+	threadReply(rtm, thread, "Ugh... it's been a while. My bones are rough. My bones are weak. Load me up with "+strconv.Itoa(session.CostGP)+"GP and our journey together will make your week.")
 }
 
 type ReceiveMoneyMsg struct {
@@ -297,27 +331,72 @@ func (msg ReceiveMoneyMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB,
 
 	typing(rtm, msg)
 
-	sessionID, output, err := aidungeonc.CreateSession(session.Prompt)
-	if err != nil {
-		handleDungeonError(rtm, msg, err)
+	session, ok := startStory(api, rtm, msg, dbc, aidungeonc, session)
+	if !ok {
 		return
 	}
 
+	threadReply(rtm, msg, "_(remember to @mention me in your replies!)_")
+
+	checkAchievements(rtm, msg, dbc, session, []db.SlackUser{session.Creator})
+
+	log.Println("SESSION ID:", session.SessionID)
+}
+
+// startStory has the engine start a paid-for session and posts its opening.
+// returns false if something went wrong.
+func startStory(api *slack.Client, rtm *slack.RTM, thread Thread, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session) (db.Session, bool) {
+	var sessionID int
+	var output string
+	var err error
+	if session.StoryMode != "" && session.StoryMode != aidungeon.StoryModeCustom {
+		sessionID, output, err = aidungeonc.CreatePresetSession(session.StoryMode, session.CharacterType, session.PresetName)
+	} else {
+		sessionID, output, err = aidungeonc.CreateSession(session.Prompt)
+	}
+	if err != nil {
+		handleDungeonError(rtm, thread, err)
+		return session, false
+	}
+
 	session, err = dbc.MarkSessionPaidAndStarted(session, sessionID)
 	if err != nil {
-		handleDBError(rtm, msg, err)
-		return
+		handleDBError(rtm, thread, err)
+		return session, false
 	}
 
-	if err := dbc.CreateStoryItem(session, "Output", nil, output); err != nil {
-		handleDBError(rtm, msg, err)
-		return
+	output, ok := moderateOutput(rtm, thread, dbc, aidungeonc, session, output)
+	if !ok {
+		return session, false
 	}
 
-	threadReply(rtm, msg, output)
-	threadReply(rtm, msg, "_(remember to @mention me in your replies!)_")
+	outputTs, err := threadPost(api, thread, output)
+	if err != nil {
+		handleSlackError(rtm, thread, err)
+		return session, false
+	}
 
-	log.Println("SESSION ID:", sessionID)
+	if err := dbc.CreateOutputStoryItem(session, output, outputTs); err != nil {
+		handleDBError(rtm, thread, err)
+		return session, false
+	}
+
+	// journeys draw on the world of the channel they're started in
+	lore, err := channelLore(dbc, session.ChannelID)
+	if err != nil {
+		handleDBError(rtm, thread, err)
+		return session, false
+	}
+
+	if len(relevantLore(approvedLore(lore), session.Prompt+"\n\n"+output)) > 0 {
+		if !pinContext(rtm, thread, dbc, aidungeonc, session, nil, output) {
+			return session, false
+		}
+	}
+
+	proposeLore(rtm, thread, dbc, session, output)
+
+	return session, true
 }
 
 type InputMsg struct {
@@ -386,44 +465,127 @@ func (msg InputMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidung
 		return
 	}
 
-	authedInput := false
-	if session.Creator.Eq(author) {
-		authedInput = true
-	} else {
-		for _, companion := range session.Companions {
-			fmt.Println(author, companion, "-", companion.Eq(author))
-			if companion.Eq(author) {
-				authedInput = true
-			}
-		}
-	}
-
-	if !authedInput {
+	if !session.IsParticipant(author) {
 		log.Println("input attempted from non-creator or contributor:", author.ToString(), "-", msg.Raw())
 		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to embark on.")
 		return
 	}
 
-	if err := dbc.CreateStoryItem(session, "Input", &author, msg.Input); err != nil {
-		handleDBError(rtm, msg, err)
+	if !checkPlayable(rtm, msg, session) {
 		return
 	}
 
-	typing(rtm, msg)
+	if currentPlayer, ok := session.CurrentPlayer(); ok && !currentPlayer.Eq(author) {
+		log.Println("out of turn input from", author.ToString(), "- waiting on", currentPlayer.ToString())
+		threadReply(rtm, msg, "Patience, my friend! It's <@"+currentPlayer.ID+">'s turn right now. I'll let you know when you're up.")
+		return
+	}
 
-	output, err := aidungeonc.Input(session.SessionID, msg.Input)
-	if err != nil {
-		handleDungeonError(rtm, msg, err)
+	if !allowInput(rtm, msg, dbc, author, msg.Input) {
 		return
 	}
 
-	if err := dbc.CreateStoryItem(session, "Output", nil, output); err != nil {
-		handleDBError(rtm, msg, err)
+	if !allowPlay(rtm, msg, dbc, author, true) {
 		return
 	}
 
-	threadReply(rtm, msg, output)
+	if session.PlayMode == db.PlayModeVote {
+		proposeAction(api, rtm, msg, dbc, session, author, msg.Input)
+		return
+	}
+
+	if !tellStory(api, rtm, msg, dbc, aidungeonc, session, author, msg.Input) {
+		return
+	}
 
+	if session.PlayMode == db.PlayModeTurns {
+		session, err = dbc.AdvanceSessionTurn(session)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		announceTurn(rtm, msg, session)
+	}
+}
+
+// tellStory sends a participant's input to the engine and replies with what
+// happens next, recording both along the way. inputs can start with a mode
+// ("say ...", "story ..."), and if the author has registered a character,
+// they're attributed to it so the engine knows who did what. false if it
+// failed, in which case the error has already been reported in the thread.
+func tellStory(api *slack.Client, rtm *slack.RTM, thread Thread, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session, author db.SlackUser, rawInput string) bool {
+	mode, input := story.ParseMode(rawInput)
+
+	// only actions can be skill checks
+	stat := ""
+	if mode == story.ModeDo {
+		stat, input = story.ParseCheck(input)
+	}
+
+	character, _ := session.CharacterName(author)
+	engineInput := story.Format(mode, character, input)
+
+	if stat != "" {
+		success, ok := skillCheck(rtm, thread, dbc, session, author, stat)
+		if !ok {
+			return false
+		}
+
+		engineInput = story.WithOutcome(engineInput, character != "", success)
+	}
+
+	inputItem, err := dbc.CreateInputStoryItem(session, author, string(mode), input, engineInput)
+	if err != nil {
+		handleDBError(rtm, thread, err)
+		return false
+	}
+
+	typing(rtm, thread)
+
+	output, err := aidungeonc.Input(session.SessionID, engineInput)
+	if err != nil {
+		handleDungeonError(rtm, thread, err)
+		return false
+	}
+
+	output, ok := moderateOutput(rtm, thread, dbc, aidungeonc, session, output)
+	if !ok {
+		// take the turn back so the story carries on from before it
+		if err := aidungeonc.Undo(session.SessionID); err != nil {
+			log.Println("unable to undo blocked turn:", err)
+		}
+
+		if err := dbc.MarkStoryItemSuperseded(inputItem); err != nil {
+			log.Println("unable to mark blocked input superseded:", err)
+		}
+
+		threadReply(rtm, thread, "What do you do instead?")
+		return false
+	}
+
+	outputTs, err := threadPost(api, thread, output)
+	if err != nil {
+		handleSlackError(rtm, thread, err)
+		return false
+	}
+
+	if err := dbc.CreateOutputStoryItem(session, output, outputTs); err != nil {
+		handleDBError(rtm, thread, err)
+		return false
+	}
+
+	drawOnLore(rtm, thread, dbc, aidungeonc, session, output)
+	proposeLore(rtm, thread, dbc, session, output)
+
+	// not worth bothering players over, it only delays auto-pausing
+	if _, err := dbc.MarkSessionActive(session); err != nil {
+		log.Println("unable to mark session active:", err)
+	}
+
+	checkTurnAchievements(rtm, thread, dbc, session, author)
+
+	return true
 }
 
 type DMMsg struct {
@@ -560,6 +722,36 @@ func (msg HelpMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidunge
 
 once we start a journey together, provide next steps and i'll generate the story (ex. `+"`@dungeon Take out the pistol you've been hiding in your back pocket`"+`). there is no limit to what we can do. your creativity is truly the limit.
 
+to talk instead, use `+"`@dungeon say \"hello there\"`"+`. to write the story yourself, use `+"`@dungeon story The door creaks open`"+`.
+
+didn't like what happened? `+"`@dungeon undo`"+` takes back the last turn, `+"`@dungeon retry`"+` has me try again and `+"`@dungeon alter <text>`"+` rewrites what i said.
+
+take a break with `+"`@dungeon pause`"+` and pick back up with `+"`@dungeon resume`"+`. when the story's told, the creator can wrap it up with `+"`@dungeon the end`"+`.
+
+wonder what would've happened if you'd chosen differently? `+"`@dungeon fork`"+` starts a new journey from where we are, and `+"`@dungeon fork 3`"+` starts one from turn 3.
+
+want to keep the story? `+"`@dungeon export`"+` sends it to you as markdown (or try `+"`export html`"+` and `+"`export text`"+`). finished journeys in public channels go in our storybook unless someone on the journey says `+"`@dungeon unpublish`"+`. journeys anywhere else stay out unless someone says `+"`@dungeon publish`"+`.
+
+joining late? `+"`@dungeon recap`"+` catches you up on the story so far. if i keep forgetting something important, `+"`@dungeon remember <fact>`"+` pins it to my memory (see them with `+"`@dungeon memories`"+`, unpin them with `+"`@dungeon forget <number>`"+`).
+
+the creator of a journey can bring people along mid-story with `+"`@dungeon invite @someone`"+`, remove them with `+"`@dungeon kick @someone`"+` or hand the whole journey off with `+"`@dungeon transfer @someone`"+`. companions can bow out with `+"`@dungeon leave`"+`.
+
+if everyone keeps talking over each other, the creator can switch to taking turns with `+"`@dungeon mode turns`"+` (and back with `+"`@dungeon mode free`"+`). for big groups, `+"`@dungeon mode vote`"+` lets everyone propose actions and vote on what happens next.
+
+playing with friends? tell me who you are with `+"`@dungeon I am Sir Bob`"+` and i'll keep track of who does what. `+"`@dungeon sheet`"+` shows your character sheet (change it with `+"`@dungeon sheet set dex 14`"+` or `+"`@dungeon sheet set hp 7`"+`), and `+"`@dungeon inventory add rope`"+` / `+"`inventory drop rope`"+` keeps track of what you're carrying.
+
+feeling lucky? `+"`@dungeon roll d20+3`"+` rolls some dice, and ending an action with a stat like `+"`@dungeon pick the lock [dex]`"+` makes it a skill check against your sheet.
+
+need inspiration? `+"`@dungeon scenarios`"+` lists the ready-made journeys i know (try `+"`@dungeon scenarios fantasy`"+`), and `+"`@dungeon play zombie-soldier as Michael`"+` starts one. or let me come up with something with `+"`@dungeon surprise me`"+`.
+
+for a classic adventure, pick a story mode and who you want to be, like `+"`@dungeon start fantasy knight named Aria`"+`. the modes are fantasy, mystery, apocalyptic and zombies.
+
+`+"`@dungeon achievements`"+` shows what you've earned and this week's quest. `+"`@dungeon stats`"+` sums up your adventures, and `+"`@dungeon leaderboard`"+` shows who's played the most this week (or `+"`leaderboard all time`"+`).
+
+every day there's a new community adventure in <#`+PlayDungeonChannelID+`> that anyone can jump into, on the house.
+
+channels can share a world across their journeys. `+"`@dungeon lore`"+` shows what i know about it, and `+"`@dungeon lore add npc Vex: a red dragon who hoards clocks`"+` proposes something new for an admin to approve.
+
 `+ScenarioIdeas,
 	)
 }
@@ -589,6 +781,59 @@ func parseMessage(msg *slack.MessageEvent) Msg {
 		return parsed
 	}
 
+	// must come before starting a journey, which takes any top-level
+	// mention as a prompt
+
+	parsed, ok = ParseAdminMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseScenariosMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParsePlayScenarioMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseSurpriseMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParsePresetMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseRollMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseLoreMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseAchievementsMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseStatsMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseLeaderboardMsg(msg)
+	if ok {
+		return parsed
+	}
+
 	// main flows, in order flow will happen
 
 	parsed, ok = ParseStartJourneyMsg(msg)
@@ -601,6 +846,64 @@ func parseMessage(msg *slack.MessageEvent) Msg {
 		return parsed
 	}
 
+	// commands inside a journey's thread, must come before input so they
+	// aren't sent along as part of the story
+
+	parsed, ok = ParseCompanionMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParsePlayModeMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseCharacterMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseRevisionMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseJourneyStatusMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseForkMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseExportMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParsePublishMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseRecapMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseMemoryMsg(msg)
+	if ok {
+		return parsed
+	}
+
+	parsed, ok = ParseSheetMsg(msg)
+	if ok {
+		return parsed
+	}
+
 	parsed, ok = ParseInputMsg(msg)
 	if ok {
 		return parsed
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// PRESET STORY MODES //
+
+// when someone wants to start a journey in one of AI Dungeon's preset story
+// modes instead of with their own prompt. without a character type, the
+// mode's default is used. without a name, the player's own name is used.
+// examples:
+//
+//	<@USH186XSP> start fantasy
+//	<@USH186XSP> start fantasy knight named Aria
+//	<@USH186XSP> start zombies scientist
+type PresetMsg struct {
+	AuthorID      string
+	StoryMode     string
+	CharacterType string
+	Name          string
+	raw           *slack.MessageEvent
+}
+
+func (m PresetMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m PresetMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m PresetMsg) ThreadTimestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m PresetMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParsePresetMsg(m *slack.MessageEvent) (*PresetMsg, bool) {
+	// cannot be in a thread
+	if m.ThreadTimestamp != "" {
+		return nil, false
+	}
+
+	// cannot be in dm
+	if strings.HasPrefix(m.Channel, "D") {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:start) ([A-Za-z]+)(?: ([A-Za-z]+))?(?: (?i:named|called) (.+?))?[.!]? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	// anything that isn't a story mode is a prompt that happens to start
+	// with "start", ex. "start the engine and drive away"
+	storyMode := strings.ToLower(matches[1])
+	if _, ok := aidungeon.StoryModes[storyMode]; !ok {
+		return nil, false
+	}
+
+	characterType := strings.ToLower(matches[2])
+	if characterType == "" {
+		characterType = aidungeon.StoryModes[storyMode][0]
+	}
+
+	return &PresetMsg{
+		AuthorID:      m.User,
+		StoryMode:     storyMode,
+		CharacterType: characterType,
+		Name:          strings.TrimSpace(matches[3]),
+		raw:           m,
+	}, true
+}
+
+func (msg PresetMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("preset journey requested:", msg)
+
+	if !aidungeon.ValidPreset(msg.StoryMode, msg.CharacterType) {
+		threadReply(rtm, msg, "You can't be a "+msg.CharacterType+" in a "+msg.StoryMode+" story! Here's who you can be:\n\n"+presetOptions())
+		return
+	}
+
+	creator, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	name := msg.Name
+	if name == "" {
+		name = creator.Name
+	}
+
+	prompt := "A " + msg.StoryMode + " story starring " + name + " the " + msg.CharacterType + "."
+	if !allowPrompt(rtm, msg, dbc, creator, prompt) {
+		return
+	}
+
+	if !allowPlay(rtm, msg, dbc, creator, false) {
+		return
+	}
+
+	session, err := dbc.CreateSession(db.Session{
+		ThreadTimestamp: msg.Timestamp(),
+		ChannelID:       msg.ChannelID(),
+		Creator:         creator,
+		CostGP:          config().CostToPlay,
+		Prompt:          prompt,
+		StoryMode:       msg.StoryMode,
+		CharacterType:   msg.CharacterType,
+		PresetName:      name,
+		Unlisted:        !isPublicChannel(api, msg.ChannelID()),
+	})
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	log.Println("PRESET SESSION CREATED", session)
+
+	askForPayment(rtm, msg, session)
+}
+
+// presetOptions lists the story modes and who can be played in each
+func presetOptions() string {
+	modes := []string{}
+	for mode := range aidungeon.StoryModes {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+
+	lines := make([]string, len(modes))
+	for i, mode := range modes {
+		lines[i] = "• `" + mode + "`: " + strings.Join(aidungeon.StoryModes[mode], ", ")
+	}
+
+	return strings.Join(lines, "\n")
+}
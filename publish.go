@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// PUBLISHING //
+
+// when participants opt a journey out of (or back into) the storybook site.
+// journeys in public channels are published once they end unless someone opts
+// out, journeys anywhere else only if someone opts in. examples:
+//
+//	<@USH186XSP> unpublish
+//	<@USH186XSP> publish
+type PublishMsg struct {
+	AuthorID string
+	Unlisted bool
+	raw      *slack.MessageEvent
+}
+
+func (m PublishMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m PublishMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m PublishMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m PublishMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParsePublishMsg(m *slack.MessageEvent) (*PublishMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:(publish|unpublish)) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &PublishMsg{
+		AuthorID: m.User,
+		Unlisted: strings.ToLower(matches[1]) == "unpublish",
+		raw:      m,
+	}, true
+}
+
+func (msg PublishMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("publishing preference received:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("publishing preference given, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.IsParticipant(author) {
+		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to decide on.")
+		return
+	}
+
+	session, err = dbc.SetSessionUnlisted(session, author, msg.Unlisted)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if msg.Unlisted {
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, "unpublished the journey"); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		threadReply(rtm, msg, "Got it, this journey stays between us. It won't show up in the storybook.")
+		return
+	}
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &author, "published the journey"); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if session.Unlisted {
+		threadReply(rtm, msg, "I'd love to, but "+mentions(session.UnlistedBy)+" asked to keep this journey out of the storybook. It stays out until they change their mind.")
+		return
+	}
+
+	threadReply(rtm, msg, "Wonderful! Once this journey comes to an end, it'll be in the storybook for all to read.")
+}
+
+// isPublicChannel checks whether anyone in the workspace can read a channel.
+// when we can't tell, it's treated as private.
+func isPublicChannel(api *slack.Client, channelID string) bool {
+	channel, err := api.GetConversationInfo(channelID, false)
+	if err != nil {
+		log.Println("unable to look up channel", channelID, "-", err)
+		return false
+	}
+
+	return !channel.IsPrivate && !channel.IsIM && !channel.IsMpIM
+}
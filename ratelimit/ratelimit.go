@@ -0,0 +1,173 @@
+// Token bucket rate limiting, ex. so one player can't flood a channel
+package ratelimit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many buckets to keep before clearing out the full ones, which are no
+// different from buckets that were never used
+const pruneThreshold = 1000
+
+// How often something can happen: bursts of up to Count, refilled at Count
+// every Per. a zero Count is unlimited.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+// ParseRate reads rates like "5/1m" (5 a minute) or "100/24h". "0" and "off"
+// are unlimited.
+func ParseRate(s string) (Rate, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "0" || s == "off" {
+		return Rate{}, nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Rate{}, errors.New("not a rate: " + s)
+	}
+
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 0 {
+		return Rate{}, errors.New("invalid count in rate: " + s)
+	}
+
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return Rate{}, errors.New("invalid duration in rate: " + s)
+	}
+
+	return Rate{Count: count, Per: per}, nil
+}
+
+func (r Rate) Unlimited() bool {
+	return r.Count == 0
+}
+
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "unlimited"
+	}
+
+	// ex. "1m" rather than "1m0s"
+	per := r.Per.String()
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+
+	return strconv.Itoa(r.Count) + "/" + per
+}
+
+// A rate applied to something, ex. a slack user
+type Limit struct {
+	Key  string
+	Rate Rate
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill tops a bucket up for the time that's passed since it was last used
+func (b *bucket) refill(rate Rate, now time.Time) {
+	capacity := float64(rate.Count)
+
+	b.tokens += now.Sub(b.updated).Seconds() * capacity / rate.Per.Seconds()
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+
+	b.updated = now
+}
+
+// how long until the bucket has a token to spare
+func (b *bucket) wait(rate Rate) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) * float64(rate.Per) / float64(rate.Count))
+}
+
+// Keeps a bucket for everything being limited. safe to use from multiple
+// goroutines.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func New() *Limiter {
+	return &Limiter{buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token from every limit's bucket if they all have one to
+// spare. otherwise nothing is taken, and it returns the limit holding things
+// up and how long until it won't be.
+func (l *Limiter) Allow(now time.Time, limits ...Limit) (time.Duration, *Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var longestWait time.Duration
+	var blocking *Limit
+	for i, limit := range limits {
+		if limit.Rate.Unlimited() {
+			continue
+		}
+
+		b := l.bucket(limit, now)
+		b.refill(limit.Rate, now)
+
+		if wait := b.wait(limit.Rate); wait > longestWait {
+			longestWait = wait
+			blocking = &limits[i]
+		}
+	}
+
+	if blocking != nil {
+		return longestWait, blocking
+	}
+
+	for _, limit := range limits {
+		if !limit.Rate.Unlimited() {
+			l.buckets[limit.Key].tokens--
+		}
+	}
+
+	return 0, nil
+}
+
+func (l *Limiter) bucket(limit Limit, now time.Time) *bucket {
+	if b, ok := l.buckets[limit.Key]; ok {
+		return b
+	}
+
+	if len(l.buckets) >= pruneThreshold {
+		l.prune(now)
+	}
+
+	b := &bucket{tokens: float64(limit.Rate.Count), updated: now}
+	l.buckets[limit.Key] = b
+
+	return b
+}
+
+// prune forgets buckets that have been idle long enough to be full again.
+// the longest any rate takes to refill isn't known here, so anything idle
+// for a day is assumed to be.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > 24*time.Hour {
+			delete(l.buckets, key)
+		}
+	}
+}
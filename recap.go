@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./story"
+)
+
+// RECAPS //
+
+// how many sentences extractive recaps are boiled down to
+const recapSentences = 6
+
+// sent to the engine after the story to get it summarizing
+const recapPrompt = "\n\nTo summarize the story so far in a few sentences:"
+
+// when someone wants to catch up on a journey. example:
+//
+//	<@USH186XSP> recap
+type RecapMsg struct {
+	AuthorID string
+	raw      *slack.MessageEvent
+}
+
+func (m RecapMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m RecapMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m RecapMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m RecapMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseRecapMsg(m *slack.MessageEvent) (*RecapMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:recap)[.!?]* *$`)
+	if !regex.MatchString(m.Text) {
+		return nil, false
+	}
+
+	return &RecapMsg{
+		AuthorID: m.User,
+		raw:      m,
+	}, true
+}
+
+func (msg RecapMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("recap requested:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("recap attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	turns := db.StoryTurns(items)
+	if len(turns) == 0 {
+		threadReply(rtm, msg, "There's nothing to recap yet!")
+		return
+	}
+
+	// nothing's happened since the last recap. undoing or revising a turn
+	// doesn't change how many there are, but it does change the last output.
+	lastOutput := turns[len(turns)-1].Output
+	if session.Recap != "" && session.RecapTurn == len(turns) && session.RecapOutput == lastOutput.AirtableID {
+		threadReply(rtm, msg, "*Previously on this journey...*\n\n"+session.Recap)
+		return
+	}
+
+	recap := ""
+	if config().RecapMode == "engine" {
+		author, err := db.SlackUserFromID(api, msg.AuthorID)
+		if err != nil {
+			handleSlackError(rtm, msg, err)
+			return
+		}
+
+		// engine recaps start a session of their own
+		if !allowPlay(rtm, msg, dbc, author, false) {
+			return
+		}
+
+		typing(rtm, msg)
+
+		recap, err = engineRecap(aidungeonc, turns)
+		if err != nil {
+			log.Println("unable to recap with engine, falling back to extractive recap:", err)
+		}
+
+		// the extractive recap only repeats outputs that already passed
+		// moderation
+		if recap != "" && !moderate(dbc, msg, "output", nil, recap) {
+			recap = ""
+		}
+	}
+
+	if recap == "" {
+		recap = story.Summarize(storyText(turns), recapSentences)
+	}
+
+	session, err = dbc.SetSessionRecap(session, recap, len(turns), lastOutput)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	threadReply(rtm, msg, "*Previously on this journey...*\n\n"+recap)
+}
+
+// engineRecap has the engine summarize the story in a throwaway session, so
+// the journey itself isn't affected
+func engineRecap(aidungeonc aidungeon.Client, turns []db.Turn) (string, error) {
+	prompt := storySoFar(turns) + recapPrompt
+
+	_, output, err := aidungeonc.CreateSession(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	// the engine's first output starts with the prompt it was given
+	return strings.TrimSpace(strings.TrimPrefix(output, prompt)), nil
+}
+
+// storyText is just the outputs of the story, without anyone's inputs
+func storyText(turns []db.Turn) string {
+	outputs := make([]string, len(turns))
+	for i, turn := range turns {
+		outputs[i] = turn.Output.Value
+	}
+
+	return strings.Join(outputs, "\n\n")
+}
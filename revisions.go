@@ -0,0 +1,288 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// STORY REVISIONS //
+
+// when a participant wants a do-over on the last turn. examples:
+//
+//	<@USH186XSP> undo
+//	<@USH186XSP> retry
+//	<@USH186XSP> alter The orc drops his axe and runs away.
+type RevisionMsg struct {
+	AuthorID string
+	Command  string
+	Text     string
+	raw      *slack.MessageEvent
+}
+
+func (m RevisionMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m RevisionMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m RevisionMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m RevisionMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseRevisionMsg(m *slack.MessageEvent) (*RevisionMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`(?s)^<@` + SelfID + `> (?:(undo|retry) *|(alter) (.+))$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	command := matches[1] + matches[2]
+	text := strings.TrimSpace(matches[3])
+
+	return &RevisionMsg{
+		AuthorID: m.User,
+		Command:  command,
+		Text:     text,
+		raw:      m,
+	}, true
+}
+
+func (msg RevisionMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("story revision requested:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("revision attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.IsParticipant(author) {
+		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to embark on.")
+		return
+	}
+
+	if !session.Paid {
+		threadReply(rtm, msg, "We haven't even started yet!")
+		return
+	}
+
+	if !checkPlayable(rtm, msg, session) {
+		return
+	}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	input, output := lastTurn(items)
+	if output == nil {
+		threadReply(rtm, msg, "I can't find anything to change. This journey might be from before I learned how.")
+		return
+	}
+
+	switch msg.Command {
+	case "undo":
+		if input == nil {
+			threadReply(rtm, msg, "There's nothing to undo, this is where our journey began!")
+			return
+		}
+
+		typing(rtm, msg)
+
+		if err := aidungeonc.Undo(session.SessionID); err != nil {
+			handleDungeonError(rtm, msg, err)
+			return
+		}
+
+		for _, item := range []db.StoryItem{*input, *output} {
+			if err := dbc.MarkStoryItemSuperseded(item); err != nil {
+				handleDBError(rtm, msg, err)
+				return
+			}
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, "undid the last turn"); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if _, err := editOrReply(api, msg, output.SlackTimestamp, "_~this part of the story has been undone~_"); err != nil {
+			handleSlackError(rtm, msg, err)
+			return
+		}
+
+		threadReply(rtm, msg, "Poof! It never happened. What do you do instead?")
+	case "retry":
+		if !allowPlay(rtm, msg, dbc, author, false) {
+			return
+		}
+
+		typing(rtm, msg)
+
+		newOutput, err := aidungeonc.Retry(session.SessionID)
+		if err != nil {
+			handleDungeonError(rtm, msg, err)
+			return
+		}
+
+		newOutput, ok := moderateOutput(rtm, msg, dbc, aidungeonc, session, newOutput)
+		if !ok {
+			takeBackBlockedRetry(api, rtm, msg, dbc, aidungeonc, session, author, input, *output)
+			return
+		}
+
+		reviseOutput(api, rtm, msg, dbc, session, author, *output, newOutput, "retried the last output")
+	case "alter":
+		if !allowInput(rtm, msg, dbc, author, msg.Text) {
+			return
+		}
+
+		if !allowPlay(rtm, msg, dbc, author, false) {
+			return
+		}
+
+		typing(rtm, msg)
+
+		if err := aidungeonc.Alter(session.SessionID, msg.Text); err != nil {
+			handleDungeonError(rtm, msg, err)
+			return
+		}
+
+		reviseOutput(api, rtm, msg, dbc, session, author, *output, msg.Text, "altered the last output")
+	}
+}
+
+// takeBackBlockedRetry undoes a turn whose retried output was blocked, like a
+// blocked turn is, so the engine doesn't carry on from an output nobody saw.
+// the opening output can't be undone, so it's put back as it was instead.
+func takeBackBlockedRetry(api *slack.Client, rtm *slack.RTM, msg Msg, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session, author db.SlackUser, input *db.StoryItem, output db.StoryItem) {
+	if input == nil {
+		if err := aidungeonc.Alter(session.SessionID, output.Value); err != nil {
+			handleDungeonError(rtm, msg, err)
+		}
+
+		return
+	}
+
+	if err := aidungeonc.Undo(session.SessionID); err != nil {
+		handleDungeonError(rtm, msg, err)
+		return
+	}
+
+	for _, item := range []db.StoryItem{*input, output} {
+		if err := dbc.MarkStoryItemSuperseded(item); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+	}
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &author, "retried the last output, which was undone"); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if _, err := editOrReply(api, msg, output.SlackTimestamp, "_~this part of the story has been undone~_"); err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	threadReply(rtm, msg, "What do you do instead?")
+}
+
+// reviseOutput replaces an output with a new one, in the store and in slack
+func reviseOutput(api *slack.Client, rtm *slack.RTM, msg Msg, dbc *db.DB, session db.Session, author db.SlackUser, old db.StoryItem, output, note string) {
+	if err := dbc.MarkStoryItemSuperseded(old); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &author, note); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	outputTs, err := editOrReply(api, msg, old.SlackTimestamp, output)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if err := dbc.CreateOutputStoryItem(session, output, outputTs); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if outputTs == old.SlackTimestamp {
+		threadReply(rtm, msg, "_(i've rewritten it above)_")
+	}
+}
+
+// lastTurn finds the latest output still in the story and the input that led
+// to it. input is nil if the output is the one the journey started with.
+func lastTurn(items []db.StoryItem) (input, output *db.StoryItem) {
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.Superseded {
+			continue
+		}
+
+		if output == nil {
+			if item.Type == "Output" {
+				output = &items[i]
+			}
+
+			continue
+		}
+
+		switch item.Type {
+		case "Input":
+			return &items[i], output
+		case "Output":
+			return nil, output
+		}
+	}
+
+	return nil, output
+}
+
+// editOrReply edits one of our earlier messages in the thread, or posts the
+// text as a new reply if we don't know which message it was. returns the
+// timestamp of the message the text ended up in.
+func editOrReply(api *slack.Client, thread Thread, ts, text string) (string, error) {
+	if ts == "" {
+		return threadPost(api, thread, text)
+	}
+
+	_, _, _, err := api.UpdateMessage(thread.ChannelID(), ts, slack.MsgOptionText(text, false), slack.MsgOptionAsUser(true))
+	if err != nil {
+		return "", err
+	}
+
+	return ts, nil
+}
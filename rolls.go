@@ -0,0 +1,164 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./dice"
+)
+
+// DICE ROLLS //
+
+// the story item type rolls are recorded as
+const rollItemType = "Roll"
+
+// skill checks roll a d20 plus the character's stat modifier
+var checkDice = dice.Dice{Count: 1, Sides: 20}
+
+// when someone wants to roll some dice. in a journey's thread, the roll is
+// recorded with the story. examples:
+//
+//	<@USH186XSP> roll d20
+//	<@USH186XSP> roll 2d6+1
+type RollMsg struct {
+	AuthorID string
+	Dice     string
+	raw      *slack.MessageEvent
+}
+
+func (m RollMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m RollMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m RollMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m RollMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseRollMsg(m *slack.MessageEvent) (*RollMsg, bool) {
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:roll) ([0-9]*[dD][0-9]+(?: *[+-] *[0-9]+)?) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &RollMsg{
+		AuthorID: m.User,
+		Dice:     strings.ReplaceAll(matches[1], " ", ""),
+		raw:      m,
+	}, true
+}
+
+func (msg RollMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	d, err := dice.Parse(msg.Dice)
+	if err != nil {
+		threadReply(rtm, msg, "I can't roll that! "+strings.ToUpper(err.Error()[:1])+err.Error()[1:]+".")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	result := d.Roll(rollSeed())
+
+	log.Println("rolled for", author.ToString(), "-", result, "- seed", result.Seed)
+
+	// rolls outside of a journey aren't recorded anywhere
+	if msg.raw.ThreadTimestamp != "" {
+		if session, err := dbc.GetSession(msg.raw.ThreadTimestamp); err == nil {
+			if err := dbc.CreateStoryItem(session, rollItemType, &author, rollRecord(result, "")); err != nil {
+				handleDBError(rtm, msg, err)
+				return
+			}
+		}
+	}
+
+	threadReply(rtm, msg, ":game_die: <@"+author.ID+"> rolls "+result.String())
+}
+
+// skillCheck rolls a check against one of the author's stats, posts the roll
+// and records it with the story. ok is false if something went wrong and the
+// input shouldn't go ahead.
+func skillCheck(rtm *slack.RTM, thread Thread, dbc *db.DB, session db.Session, author db.SlackUser, stat string) (success bool, ok bool) {
+	sheets, err := dbc.GetCharacterSheets(session)
+	if err != nil {
+		handleDBError(rtm, thread, err)
+		return false, false
+	}
+
+	// players without a sheet are average at everything
+	name := author.Name
+	if character, ok := session.CharacterName(author); ok {
+		name = character
+	}
+
+	d := checkDice
+	for _, sheet := range sheets {
+		if sheet.Player.Eq(author) {
+			name = sheet.Name
+			d.Modifier = dice.Modifier(sheet.Stats[stat])
+		}
+	}
+
+	result := d.Roll(rollSeed())
+	success = result.Total >= config().CheckDifficulty
+
+	log.Println(stat, "check for", author.ToString(), "-", result, "- seed", result.Seed, "- success:", success)
+
+	if err := dbc.CreateStoryItem(session, rollItemType, &author, rollRecord(result, stat)); err != nil {
+		handleDBError(rtm, thread, err)
+		return false, false
+	}
+
+	outcome := "Failure!"
+	if success {
+		outcome = "Success!"
+	}
+
+	threadReply(rtm, thread, ":game_die: *"+name+"* rolls "+stat+" ("+result.String()+") against "+strconv.Itoa(config().CheckDifficulty)+". "+outcome)
+
+	return success, true
+}
+
+// every roll gets its own seed, which is logged and recorded so any roll can
+// be checked later
+func rollSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// rollRecord is how a roll is written down in the store
+func rollRecord(result dice.Result, stat string) string {
+	record := result.String()
+	if stat != "" {
+		outcome := "failure"
+		if result.Total >= config().CheckDifficulty {
+			outcome = "success"
+		}
+
+		record = stat + " check, " + record + " against " + strconv.Itoa(config().CheckDifficulty) + ", " + outcome
+	}
+
+	return record + " (seed " + strconv.FormatInt(result.Seed, 10) + ")"
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./transcript"
+)
+
+// SCENARIO LIBRARY //
+
+var placeholderRegex = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// how often the library's scenario names are refreshed from the store
+const scenarioRefreshInterval = time.Hour
+
+// the library's scenario names, lowercased, so "play ..." prompts that aren't
+// scenarios can be told apart without a trip to the store
+var (
+	scenarioNamesMu       sync.RWMutex
+	scenarioNames         = map[string]bool{}
+	scenarioNamesLoadedAt time.Time
+)
+
+// loadScenarioNames refreshes the scenario names from the store
+func loadScenarioNames(dbc *db.DB) error {
+	scenarios, err := dbc.ListScenarios("")
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, scenario := range scenarios {
+		names[strings.ToLower(scenario.Name)] = true
+	}
+
+	scenarioNamesMu.Lock()
+	defer scenarioNamesMu.Unlock()
+
+	scenarioNames = names
+	scenarioNamesLoadedAt = time.Now()
+
+	return nil
+}
+
+// refreshScenarioNames picks up scenarios added to the library since they
+// were last loaded
+func refreshScenarioNames(dbc *db.DB) {
+	scenarioNamesMu.RLock()
+	loadedAt := scenarioNamesLoadedAt
+	scenarioNamesMu.RUnlock()
+
+	if time.Since(loadedAt) < scenarioRefreshInterval {
+		return
+	}
+
+	if err := loadScenarioNames(dbc); err != nil {
+		log.Println("unable to refresh scenario names:", err)
+	}
+}
+
+func isScenario(name string) bool {
+	scenarioNamesMu.RLock()
+	defer scenarioNamesMu.RUnlock()
+
+	return scenarioNames[strings.ToLower(name)]
+}
+
+// when someone wants to browse the scenario library, optionally by tag.
+// examples:
+//
+//	<@USH186XSP> scenarios
+//	<@USH186XSP> scenarios fantasy
+type ScenariosMsg struct {
+	Tag string
+	raw *slack.MessageEvent
+}
+
+func (m ScenariosMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m ScenariosMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m ScenariosMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m ScenariosMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseScenariosMsg(m *slack.MessageEvent) (*ScenariosMsg, bool) {
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:scenarios)(?: ([A-Za-z0-9-]+))? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &ScenariosMsg{
+		Tag: strings.ToLower(matches[1]),
+		raw: m,
+	}, true
+}
+
+func (msg ScenariosMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	scenarios, err := dbc.ListScenarios(msg.Tag)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if len(scenarios) == 0 {
+		if msg.Tag != "" {
+			threadReply(rtm, msg, "I don't know any "+msg.Tag+" scenarios yet. `@dungeon scenarios` shows all the ones I do know.")
+		} else {
+			threadReply(rtm, msg, "My scenario library is empty! You'll have to come up with a prompt yourself.\n\n"+ScenarioIdeas)
+		}
+
+		return
+	}
+
+	lines := []string{"here are the scenarios i know. start one with `@dungeon play <scenario> as <your name>`:", ""}
+	for _, scenario := range scenarios {
+		cost := scenario.CostGP
+		if cost == 0 {
+			cost = config().CostToPlay
+		}
+
+		line := "• `" + scenario.Name + "`"
+		if len(scenario.Tags) > 0 {
+			line += " (" + strings.Join(scenario.Tags, ", ") + ")"
+		}
+		line += " — " + strconv.Itoa(cost) + "GP\n    _" + transcript.Title(scenario.Prompt) + "_"
+
+		lines = append(lines, line)
+	}
+
+	threadReply(rtm, msg, strings.Join(lines, "\n"))
+}
+
+// when someone wants to start a journey from the scenario library. the name
+// and item fill in the scenario's {name} and {item} placeholders. without a
+// name, the player's own name is used. examples:
+//
+//	<@USH186XSP> play zombie-soldier
+//	<@USH186XSP> play zombie-soldier as Michael
+//	<@USH186XSP> play lost-courier as Ada with a small pistol
+type PlayScenarioMsg struct {
+	AuthorID string
+	Scenario string
+	Name     string
+	Item     string
+	raw      *slack.MessageEvent
+}
+
+func (m PlayScenarioMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m PlayScenarioMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m PlayScenarioMsg) ThreadTimestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m PlayScenarioMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParsePlayScenarioMsg(m *slack.MessageEvent) (*PlayScenarioMsg, bool) {
+	// cannot be in a thread
+	if m.ThreadTimestamp != "" {
+		return nil, false
+	}
+
+	// cannot be in dm
+	if strings.HasPrefix(m.Channel, "D") {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:play) ([A-Za-z0-9-]+)(?: (?i:as) (.+?))?(?: (?i:with) (.+?))?[.!]? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	// "play dead." is a prompt, not a scenario
+	if !isScenario(matches[1]) {
+		return nil, false
+	}
+
+	return &PlayScenarioMsg{
+		AuthorID: m.User,
+		Scenario: strings.ToLower(matches[1]),
+		Name:     strings.TrimSpace(matches[2]),
+		Item:     strings.TrimSpace(matches[3]),
+		raw:      m,
+	}, true
+}
+
+func (msg PlayScenarioMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("scenario requested:", msg)
+
+	scenario, err := dbc.GetScenario(msg.Scenario)
+	if err != nil {
+		log.Println("unable to find scenario:", err, "-", msg)
+		threadReply(rtm, msg, "I don't know a scenario called `"+msg.Scenario+"`. `@dungeon scenarios` shows the ones I do know.")
+		return
+	}
+
+	creator, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	name := msg.Name
+	if name == "" {
+		name = creator.Name
+	}
+
+	prompt, missing := fillScenario(scenario.Prompt, map[string]string{
+		"name": name,
+		"item": msg.Item,
+	})
+	if len(missing) > 0 {
+		threadReply(rtm, msg, "This scenario needs a little more from you! Try something like `@dungeon play "+scenario.Name+" as "+name+" with a rusty sword` (it's missing: "+strings.Join(missing, ", ")+").")
+		return
+	}
+
+	if !allowPrompt(rtm, msg, dbc, creator, prompt) {
+		return
+	}
+
+	if !allowPlay(rtm, msg, dbc, creator, false) {
+		return
+	}
+
+	cost := scenario.CostGP
+	if cost == 0 {
+		cost = config().CostToPlay
+	}
+
+	session, err := dbc.CreateSession(db.Session{
+		ThreadTimestamp:    msg.Timestamp(),
+		ChannelID:          msg.ChannelID(),
+		Creator:            creator,
+		CostGP:             cost,
+		Prompt:             prompt,
+		ScenarioAirtableID: scenario.AirtableID,
+		Unlisted:           !isPublicChannel(api, msg.ChannelID()),
+	})
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	log.Println("SESSION CREATED FROM SCENARIO", scenario.Name, session)
+
+	threadReply(rtm, msg, "_"+prompt+"_")
+
+	askForPayment(rtm, msg, session)
+}
+
+// fillScenario replaces a scenario prompt's placeholders with the given
+// values, returning any placeholders that were left without one
+func fillScenario(template string, values map[string]string) (string, []string) {
+	missing := []string{}
+
+	prompt := placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := placeholderRegex.FindStringSubmatch(placeholder)[1]
+		if value := values[key]; value != "" {
+			return value
+		}
+
+		if !containsString(missing, key) {
+			missing = append(missing, key)
+		}
+
+		return placeholder
+	})
+
+	return prompt, missing
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./story"
+)
+
+// CHARACTER SHEETS //
+
+// what new character sheets start with
+const (
+	defaultStat = 10
+	defaultHP   = 10
+)
+
+// the most HP a character can have
+const maxHP = 999
+
+// when a participant wants to see or change their character sheet or
+// inventory. examples:
+//
+//	<@USH186XSP> sheet
+//	<@USH186XSP> sheet set dex 14
+//	<@USH186XSP> sheet set hp 7
+//	<@USH186XSP> inventory
+//	<@USH186XSP> inventory add rope
+//	<@USH186XSP> inventory drop small dagger
+type SheetMsg struct {
+	AuthorID string
+	// "sheet" or "inventory"
+	Command string
+	// for sheet, "set". for inventory, "add", "remove" or "drop". empty to
+	// just show it.
+	Action string
+	// for sheet set, the stat (as in db.Stats), "HP" or "MAX HP"
+	Stat  string
+	Value int
+	Item  string
+	raw   *slack.MessageEvent
+}
+
+func (m SheetMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m SheetMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m SheetMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m SheetMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseSheetMsg(m *slack.MessageEvent) (*SheetMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`(?s)^<@` + SelfID + `> (?i:(sheet)(?: (set) (str|dex|con|int|wis|cha|hp|max ?hp) (-?[0-9]+))?|(inventory)(?: (add|remove|drop) (.+?))?)[.!]? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	stat := strings.ToUpper(matches[3])
+	if stat == "MAXHP" {
+		stat = "MAX HP"
+	}
+
+	value := 0
+	if matches[4] != "" {
+		var err error
+		value, err = strconv.Atoi(matches[4])
+
+		// HP too big to parse comes back as big as can be, which is turned
+		// down with everything else out of range
+		numErr, ok := err.(*strconv.NumError)
+		isHP := stat == "HP" || stat == "MAX HP"
+		if err != nil && !(isHP && ok && numErr.Err == strconv.ErrRange) {
+			return nil, false
+		}
+	}
+
+	return &SheetMsg{
+		AuthorID: m.User,
+		Command:  strings.ToLower(matches[1] + matches[5]),
+		Action:   strings.ToLower(matches[2] + matches[6]),
+		Stat:     stat,
+		Value:    value,
+		Item:     strings.TrimSpace(matches[7]),
+		raw:      m,
+	}, true
+}
+
+func (msg SheetMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("character sheet command:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("character sheet command attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.IsParticipant(author) {
+		threadReply(rtm, msg, "...sorry my friend, but this isn't your journey to embark on.")
+		return
+	}
+
+	sheets, err := dbc.GetCharacterSheets(session)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	sheet, isNew := characterSheet(session, sheets, author)
+	note := ""
+
+	switch msg.Action {
+	case "set":
+		switch msg.Stat {
+		case "HP":
+			if msg.Value < 0 || msg.Value > sheet.MaxHP {
+				threadReply(rtm, msg, "HP has to be between 0 and your max HP of "+strconv.Itoa(sheet.MaxHP)+".")
+				return
+			}
+
+			sheet.HP = msg.Value
+		case "MAX HP":
+			if msg.Value < 1 || msg.Value > maxHP {
+				threadReply(rtm, msg, "Max HP has to be between 1 and "+strconv.Itoa(maxHP)+".")
+				return
+			}
+
+			// lowering max HP takes any extra off current HP too
+			sheet.MaxHP = msg.Value
+			if sheet.HP > sheet.MaxHP {
+				sheet.HP = sheet.MaxHP
+			}
+		default:
+			sheet.Stats[msg.Stat] = msg.Value
+		}
+
+		note = "set " + msg.Stat + " to " + strconv.Itoa(msg.Value)
+	case "add":
+		if !allowInput(rtm, msg, dbc, author, msg.Item) {
+			return
+		}
+
+		sheet.Inventory = append(sheet.Inventory, msg.Item)
+		note = "picked up " + msg.Item
+	case "remove", "drop":
+		i := findItem(sheet.Inventory, msg.Item)
+		if i == -1 {
+			threadReply(rtm, msg, "You don't have "+msg.Item+"! You're carrying "+inventoryList(sheet.Inventory)+".")
+			return
+		}
+
+		note = "dropped " + sheet.Inventory[i]
+		sheet.Inventory = append(sheet.Inventory[:i], sheet.Inventory[i+1:]...)
+	}
+
+	if isNew || note != "" {
+		sheet, err = dbc.SaveCharacterSheet(session, sheet)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+	}
+
+	if note != "" {
+		if err := dbc.CreateStoryItem(session, "Metadata", &author, note); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		if config().SheetsInContext && session.Paid {
+			items, err := dbc.GetStoryItems(session)
+			if err != nil {
+				handleDBError(rtm, msg, err)
+				return
+			}
+
+			if !pinContext(rtm, msg, dbc, aidungeonc, session, activeMemories(items), recentStory(items)) {
+				return
+			}
+		}
+	}
+
+	if msg.Command == "inventory" {
+		threadReply(rtm, msg, "*"+sheet.Name+"* is carrying "+inventoryList(sheet.Inventory)+".")
+		return
+	}
+
+	threadReply(rtm, msg, formatSheet(sheet))
+}
+
+// characterSheet finds a player's sheet, or starts a new one seeded from the
+// journey's prompt if they don't have one yet
+func characterSheet(session db.Session, sheets []db.CharacterSheet, player db.SlackUser) (db.CharacterSheet, bool) {
+	for _, sheet := range sheets {
+		if sheet.Player.Eq(player) {
+			return sheet, false
+		}
+	}
+
+	promptName, promptItems := story.ParseOpening(session.Prompt)
+
+	// the prompt is written for whoever started the journey, so only they
+	// start out with what it names
+	isCreator := player.Eq(session.Creator)
+
+	name, ok := session.CharacterName(player)
+	if !ok && isCreator {
+		name = session.PresetName
+		if name == "" {
+			name = promptName
+		}
+	}
+	if name == "" {
+		name = player.Name
+	}
+
+	items := []string{}
+	if isCreator {
+		items = promptItems
+	}
+
+	stats := map[string]int{}
+	for _, stat := range db.Stats {
+		stats[stat] = defaultStat
+	}
+
+	return db.CharacterSheet{
+		Player:    player,
+		Name:      name,
+		Stats:     stats,
+		HP:        defaultHP,
+		MaxHP:     defaultHP,
+		Inventory: items,
+	}, true
+}
+
+// findItem finds an item in an inventory, ignoring case and articles like "a"
+// and "the". -1 if it's not there.
+func findItem(inventory []string, item string) int {
+	item = strings.ToLower(strings.TrimSpace(item))
+	for _, article := range []string{"a ", "an ", "the ", "my ", "some "} {
+		item = strings.TrimPrefix(item, article)
+	}
+
+	for i, have := range inventory {
+		if strings.ToLower(have) == item {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func inventoryList(inventory []string) string {
+	if len(inventory) == 0 {
+		return "nothing"
+	}
+
+	return strings.Join(inventory, ", ")
+}
+
+func formatSheet(sheet db.CharacterSheet) string {
+	stats := make([]string, len(db.Stats))
+	for i, stat := range db.Stats {
+		stats[i] = stat + " " + strconv.Itoa(sheet.Stats[stat])
+	}
+
+	return "*" + sheet.Name + "* (<@" + sheet.Player.ID + ">)\n" +
+		"HP " + strconv.Itoa(sheet.HP) + "/" + strconv.Itoa(sheet.MaxHP) + "\n" +
+		strings.Join(stats, " · ") + "\n" +
+		"Carrying: " + inventoryList(sheet.Inventory)
+}
+
+// sheetSummary describes a character sheet for the engine's context
+func sheetSummary(sheet db.CharacterSheet) string {
+	return sheet.Name + " has " + strconv.Itoa(sheet.HP) + " of " + strconv.Itoa(sheet.MaxHP) + " HP and is carrying " + inventoryList(sheet.Inventory) + "."
+}
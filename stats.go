@@ -0,0 +1,226 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./transcript"
+)
+
+// STATS AND LEADERBOARDS //
+
+// how many players and journeys leaderboards show
+const leaderboardSize = 5
+
+// when someone wants to see their own history, or someone else's. examples:
+//
+//	<@USH186XSP> stats
+//	<@USH186XSP> stats <@U0C7B14Q3>
+type StatsMsg struct {
+	AuthorID string
+	// empty for the author's own stats
+	PlayerID string
+	raw      *slack.MessageEvent
+}
+
+func (m StatsMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m StatsMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m StatsMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m StatsMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseStatsMsg(m *slack.MessageEvent) (*StatsMsg, bool) {
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:stats)(?: <@([A-Z0-9]+)>)? *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &StatsMsg{
+		AuthorID: m.User,
+		PlayerID: matches[1],
+		raw:      m,
+	}, true
+}
+
+func (msg StatsMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	playerID := msg.PlayerID
+	if playerID == "" {
+		playerID = msg.AuthorID
+	}
+
+	player, err := db.SlackUserFromID(api, playerID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	typing(rtm, msg)
+
+	stats, err := dbc.GetPlayerStats(player.ID)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if stats.JourneysStarted == 0 && stats.JourneysJoined == 0 {
+		threadReply(rtm, msg, "*"+transcript.Name(player)+"* hasn't been on any journeys yet!")
+		return
+	}
+
+	favorites := []string{}
+	for i, companion := range stats.FavoriteCompanions {
+		if i == 3 {
+			break
+		}
+
+		favorites = append(favorites, transcript.Name(companion.User)+" ("+plural(companion.Count, "journey")+")")
+	}
+
+	lines := []string{
+		":bar_chart: *" + transcript.Name(player) + "*'s adventures so far:",
+		"",
+		"• journeys started: " + strconv.Itoa(stats.JourneysStarted),
+		"• journeys joined: " + strconv.Itoa(stats.JourneysJoined),
+		"• turns played: " + strconv.Itoa(stats.TurnsPlayed),
+		"• GP spent: " + strconv.Itoa(stats.GPSpent),
+	}
+
+	if len(favorites) > 0 {
+		lines = append(lines, "• favorite companions: "+strings.Join(favorites, ", "))
+	}
+
+	threadReply(rtm, msg, strings.Join(lines, "\n"))
+}
+
+// when someone wants to see who's played the most and the longest journeys,
+// this week or of all time. examples:
+//
+//	<@USH186XSP> leaderboard
+//	<@USH186XSP> leaderboard all time
+type LeaderboardMsg struct {
+	AllTime bool
+	raw     *slack.MessageEvent
+}
+
+func (m LeaderboardMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m LeaderboardMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m LeaderboardMsg) ThreadTimestamp() string {
+	// like help, reply in the existing thread or start a new one
+	if m.raw.ThreadTimestamp != "" {
+		return m.raw.ThreadTimestamp
+	}
+
+	return m.raw.Timestamp
+}
+
+func (m LeaderboardMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseLeaderboardMsg(m *slack.MessageEvent) (*LeaderboardMsg, bool) {
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:leaderboard(?: (?:this )?(week)| (all)(?: time)?| (ever))?) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &LeaderboardMsg{
+		AllTime: matches[2] != "" || matches[3] != "",
+		raw:     m,
+	}, true
+}
+
+func (msg LeaderboardMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	since := weekStart(time.Now())
+	heading := ":crown: *this week's leaderboard*"
+	if msg.AllTime {
+		since = time.Time{}
+		heading = ":crown: *the all time leaderboard*"
+	}
+
+	typing(rtm, msg)
+
+	players, err := dbc.TopPlayers(since, leaderboardSize)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	journeys, err := dbc.LongestJourneys(since, leaderboardSize)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if len(players) == 0 {
+		if msg.AllTime {
+			threadReply(rtm, msg, "Nobody's played yet! Be the first.")
+		} else {
+			threadReply(rtm, msg, "Nobody's played yet this week! Be the first.")
+		}
+
+		return
+	}
+
+	lines := []string{heading, "", "most turns played:"}
+	for i, player := range players {
+		lines = append(lines, strconv.Itoa(i+1)+". "+transcript.Name(player.User)+", "+plural(player.Count, "turn"))
+	}
+
+	lines = append(lines, "", "longest journeys:")
+	for i, journey := range journeys {
+		title := transcript.Title(journey.Session.Prompt)
+
+		// sessions from before we tracked channels can't be linked to
+		if journey.Session.ChannelID != "" {
+			link, err := api.GetPermalink(&slack.PermalinkParameters{
+				Channel: journey.Session.ChannelID,
+				Ts:      journey.Session.ThreadTimestamp,
+			})
+			if err == nil {
+				title = "<" + link + "|" + title + ">"
+			}
+		}
+
+		lines = append(lines, strconv.Itoa(i+1)+". "+title+", "+plural(journey.Turns, "turn")+" by "+transcript.Name(journey.Session.Creator))
+	}
+
+	threadReply(rtm, msg, strings.Join(lines, "\n"))
+}
+
+// ex. "1 turn", "3 turns"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+
+	return strconv.Itoa(n) + " " + noun + "s"
+}
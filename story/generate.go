@@ -0,0 +1,91 @@
+package story
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// pieces opening prompts are built from, in the style of the scenario ideas
+// in help. every combination should read naturally.
+var (
+	generatorNames = []string{
+		"Ada", "Bram", "Cordelia", "Dmitri", "Elspeth", "Farah", "Gideon",
+		"Hana", "Idris", "Juniper", "Kofi", "Lucia", "Marlowe", "Nadia",
+		"Orin", "Priya", "Quentin", "Rosalind", "Soren", "Thea",
+	}
+
+	generatorRoles = []string{
+		"a knight", "a noble", "a courier", "a wizard's apprentice",
+		"a ship's cook", "a detective", "a hacker", "a blacksmith",
+		"a bard", "a retired soldier", "a smuggler", "a lighthouse keeper",
+		"a botanist", "a thief", "a pop star",
+	}
+
+	generatorSettings = []string{
+		"living in the kingdom of Larion",
+		"trying to survive in a post apocalyptic world",
+		"living in the future-city of Neosporia in the year 2999",
+		"stationed on a space station orbiting Neptune",
+		"living in a small fishing village on a foggy coast",
+		"trying to make a living in a city overrun by the undead",
+		"living in a floating city above the clouds",
+		"working in an old hospital in Chicago",
+		"living deep in an enchanted forest",
+		"traveling the desert trade routes of Khazra",
+	}
+
+	generatorItems = []string{
+		"a pouch of gold", "a small dagger", "a hospital bracelet",
+		"a pack of bandages", "a parcel of letters", "a small pistol",
+		"an automatic rifle", "a grenade", "a rusty lantern",
+		"a map with one corner torn off", "a silver locket",
+		"a flask of something strong", "a coil of rope",
+		"a book of half-remembered spells", "a broken compass",
+		"a stolen keycard",
+	}
+
+	generatorIncidents = []string{
+		"You are awakened by one of your servants who tells you that your home is under attack. You look out the window and see an army marching towards you, led by a huge figure named",
+		"You wake up in a room you don't recognize with no memory of how you got there. The door to your right leads out into",
+		"A stranger presses a sealed envelope into your hand and vanishes into the crowd. Inside, written in shaky handwriting, are the words",
+		"The ground starts to shake, and a crack opens up right in front of you. From deep inside it, you hear",
+		"You've been hired to deliver a package across dangerous country, and you've just been told not to open it under any circumstances. You set out in the morning and",
+		"Alarms start blaring all around you. Over the speakers, a calm voice announces that",
+		"An old friend you thought was dead knocks on your door in the middle of the night. They look terrified and whisper",
+		"You've spent weeks searching for the lost temple, and at last you see its entrance. Standing in front of it is",
+	}
+)
+
+// Generate builds a random opening prompt. the same seed always gives the same
+// prompt.
+func Generate(seed int64) string {
+	r := rand.New(rand.NewSource(seed))
+
+	pick := func(choices []string) string {
+		return choices[r.Intn(len(choices))]
+	}
+
+	name := pick(generatorNames)
+	role := pick(generatorRoles)
+	setting := pick(generatorSettings)
+
+	// two different items
+	first := r.Intn(len(generatorItems))
+	second := r.Intn(len(generatorItems) - 1)
+	if second >= first {
+		second++
+	}
+
+	return strings.Join([]string{
+		"You are " + name + ", " + role + " " + setting + ".",
+		"You have " + generatorItems[first] + " and " + generatorItems[second] + ".",
+		pick(generatorIncidents),
+	}, " ")
+}
+
+// GenerateName picks a character name for a prompt. the same seed always
+// gives the same name.
+func GenerateName(seed int64) string {
+	r := rand.New(rand.NewSource(seed))
+	return generatorNames[r.Intn(len(generatorNames))]
+}
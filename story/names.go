@@ -0,0 +1,42 @@
+package story
+
+import (
+	"regexp"
+	"strings"
+)
+
+var introducedRegex = regexp.MustCompile(`\b(?:([A-Za-z]+) )?(?:named|called) ([A-Z][a-z]+(?: [A-Z][a-z]+)?)`)
+
+// A character or place a story introduced by name, with the sentence it was
+// introduced in
+type Introduction struct {
+	Name string
+	// the word they were introduced as, ex. "orc" in "an orc named Grak"
+	Noun     string
+	Sentence string
+}
+
+// Introductions finds everyone and everything a story introduces by name, ex.
+// "an orc named Grak" introduces Grak
+func Introductions(text string) []Introduction {
+	introductions := []Introduction{}
+	seen := map[string]bool{}
+
+	for _, sentence := range sentenceRegex.FindAllString(text, -1) {
+		for _, matches := range introducedRegex.FindAllStringSubmatch(sentence, -1) {
+			name := matches[2]
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			introductions = append(introductions, Introduction{
+				Name:     name,
+				Noun:     strings.ToLower(matches[1]),
+				Sentence: strings.TrimSpace(sentence),
+			})
+		}
+	}
+
+	return introductions
+}
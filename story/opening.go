@@ -0,0 +1,36 @@
+package story
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	openingNameRegex  = regexp.MustCompile(`\bYou are ([A-Z][^,.!?]{0,39}),`)
+	openingItemsRegex = regexp.MustCompile(`\bYou have ((?:a|an|the|some|your|[0-9]+) [^.!?]+)[.!?]`)
+	articleRegex      = regexp.MustCompile(`(?i)^(?:a|an|the|some|your) `)
+)
+
+// ParseOpening picks out who the player is and what they're carrying from an
+// opening prompt in the usual style, ex. "You are Jenny, a patient living in
+// Chicago. You have a hospital bracelet and a pack of bandages." name is
+// empty and items is nil if the prompt doesn't say.
+func ParseOpening(prompt string) (name string, items []string) {
+	if matches := openingNameRegex.FindStringSubmatch(prompt); matches != nil {
+		name = strings.TrimSpace(matches[1])
+	}
+
+	if matches := openingItemsRegex.FindStringSubmatch(prompt); matches != nil {
+		list := strings.ReplaceAll(matches[1], ", and ", ", ")
+		list = strings.ReplaceAll(list, " and ", ", ")
+
+		for _, item := range strings.Split(list, ",") {
+			item = articleRegex.ReplaceAllString(strings.TrimSpace(item), "")
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return name, items
+}
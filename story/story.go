@@ -0,0 +1,331 @@
+// Shaping player inputs into story text for the engine
+package story
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// verbs that don't follow the usual rules in the third person
+var irregularVerbs = map[string]string{
+	"be":   "is",
+	"am":   "is",
+	"are":  "is",
+	"have": "has",
+	"do":   "does",
+	"go":   "goes",
+	"were": "was",
+	// negatives of the above. other contractions, like "can't" and
+	// "won't", are the same in every person
+	"don't":   "doesn't",
+	"haven't": "hasn't",
+	"aren't":  "isn't",
+	"weren't": "wasn't",
+}
+
+// modal verbs, which are the same in every person
+var modalVerbs = map[string]bool{
+	"can": true, "could": true, "will": true, "would": true, "shall": true,
+	"should": true, "may": true, "might": true, "must": true,
+}
+
+// Kinds of input, like the AI Dungeon client's do / say / story
+type Mode string
+
+const (
+	// an action the player takes, ex. "open the door"
+	ModeDo Mode = "Do"
+	// something the player says out loud, ex. `say "hello"`
+	ModeSay Mode = "Say"
+	// text added to the story as-is
+	ModeStory Mode = "Story"
+)
+
+// ParseMode splits the mode prefix off an input. inputs without a prefix are
+// actions, unless they're just a quote.
+func ParseMode(input string) (Mode, string) {
+	input = strings.TrimSpace(input)
+	lower := strings.ToLower(input)
+
+	switch {
+	case strings.HasPrefix(lower, "say "):
+		return ModeSay, strings.TrimSpace(input[len("say "):])
+	case strings.HasPrefix(lower, "story "):
+		return ModeStory, strings.TrimSpace(input[len("story "):])
+	case strings.HasPrefix(input, `"`), strings.HasPrefix(input, `“`):
+		return ModeSay, input
+	}
+
+	return ModeDo, input
+}
+
+// Format normalizes an input into what's sent to the engine. actions and
+// dialogue are told in the second person ("You open the door.") unless the
+// player has a character name, in which case they're attributed to it ("Sir
+// Bob opens the door."). story text is left alone.
+func Format(mode Mode, character, input string) string {
+	input = strings.TrimSpace(input)
+
+	switch mode {
+	case ModeStory:
+		return input
+	case ModeSay:
+		if character == "" {
+			return "You say " + quote(input)
+		}
+
+		return character + " says " + quote(input)
+	}
+
+	if character == "" {
+		return punctuate(SecondPerson(input))
+	}
+
+	return punctuate(Attribute(character, input))
+}
+
+// SecondPerson rewrites a player's input as an action they take, ex. "I open
+// my bag" becomes "You open your bag"
+func SecondPerson(input string) string {
+	words := strings.Fields(input)
+	if len(words) == 0 {
+		return "You"
+	}
+
+	switch firstWord(words[0]) {
+	case "i", "you":
+		if len(words) == 1 {
+			return "You"
+		}
+
+		words = words[1:]
+	case "i'm", "im", "you're":
+		return joinWords("You're", words[1:])
+	case "i'll", "you'll":
+		return joinWords("You'll", words[1:])
+	case "i've", "you've":
+		return joinWords("You've", words[1:])
+	case "i'd", "you'd":
+		return joinWords("You'd", words[1:])
+	}
+
+	// "Open the door" reads "You open the door"
+	if words[0] != "I" {
+		words[0] = lowerFirst(words[0])
+	}
+
+	return joinWords("You", words)
+}
+
+// joinWords puts the rest of a second person input after its subject, with
+// first person pronouns swapped for second person ones
+func joinWords(subject string, words []string) string {
+	for i := range words {
+		words[i] = replacePronouns(words[i], secondPersonPronouns)
+	}
+
+	return strings.Join(append([]string{subject}, words...), " ")
+}
+
+// firstWord normalizes the first word of an input for matching, ex. "I’m"
+// (with a curly apostrophe) is "i'm"
+func firstWord(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "’", "'")
+}
+
+// lowerFirst lowercases the first letter of a word, which may be more than
+// one byte
+func lowerFirst(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToLower(r)) + word[size:]
+}
+
+// first person pronouns from the player's point of view
+var secondPersonPronouns = map[string]string{
+	"i":      "you",
+	"me":     "you",
+	"my":     "your",
+	"mine":   "yours",
+	"myself": "yourself",
+}
+
+// first person pronouns and who they refer to once attributed. pronouns aren't
+// known for characters, so they're always they/them.
+var thirdPersonPronouns = map[string]string{
+	"i":      "they",
+	"me":     "them",
+	"my":     "their",
+	"mine":   "theirs",
+	"myself": "themself",
+	"you":    "they",
+	"your":   "their",
+	"yours":  "theirs",
+}
+
+var wordRegex = regexp.MustCompile(`[A-Za-z']+`)
+
+// Attribute rewrites a player's input as a third person action taken by their
+// character, ex. "open the door" by "Sir Bob" becomes "Sir Bob opens the
+// door". Quoted input is treated as something the character says.
+func Attribute(name, input string) string {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return name
+	}
+
+	if strings.HasPrefix(input, `"`) || strings.HasPrefix(input, `“`) {
+		return name + " says " + input
+	}
+
+	words := strings.Fields(input)
+
+	// "I open the door" and "you open the door" are both just "open the
+	// door"
+	switch firstWord(words[0]) {
+	case "i", "you":
+		if len(words) == 1 {
+			return name
+		}
+
+		words = words[1:]
+	case "i'm", "im", "you're":
+		words[0] = "am"
+	case "i'll", "you'll":
+		words[0] = "will"
+	case "i've", "you've":
+		words[0] = "have"
+	case "i'd", "you'd":
+		words[0] = "would"
+	}
+
+	// "Carefully open the box" reads "Sir Bob carefully opens the box"
+	words[0] = lowerFirst(words[0])
+
+	// "quickly open the door" conjugates the verb after the adverb
+	verb := 0
+	for verb < len(words)-1 && isAdverb(words[verb]) {
+		verb++
+	}
+	words[verb] = conjugate(words[verb])
+
+	for i := range words {
+		if i == verb {
+			continue
+		}
+
+		words[i] = replacePronouns(words[i], thirdPersonPronouns)
+	}
+
+	return name + " " + strings.Join(words, " ")
+}
+
+// verbs that end in "ly" like most adverbs do
+var lyVerbs = map[string]bool{
+	"reply": true, "apply": true, "supply": true, "comply": true,
+	"imply": true, "multiply": true, "rally": true, "tally": true,
+	"bully": true, "dally": true, "sully": true, "belly": true,
+}
+
+// isAdverb guesses whether a word is an adverb like "quickly". short words
+// like "fly" and "rely" are almost always verbs.
+func isAdverb(word string) bool {
+	word = strings.ToLower(strings.Trim(word, ",;:"))
+	return len(word) > 4 && strings.HasSuffix(word, "ly") && !lyVerbs[word]
+}
+
+func replacePronouns(word string, pronouns map[string]string) string {
+	return wordRegex.ReplaceAllStringFunc(word, func(w string) string {
+		if pronoun, ok := pronouns[strings.ToLower(w)]; ok {
+			return pronoun
+		}
+
+		return w
+	})
+}
+
+// wraps dialogue in quotes unless it already is
+func quote(input string) string {
+	if strings.HasPrefix(input, `"`) || strings.HasPrefix(input, `“`) {
+		return input
+	}
+
+	return `"` + input + `"`
+}
+
+// ends the input like a sentence unless it already is
+func punctuate(input string) string {
+	if strings.HasSuffix(input, ".") || strings.HasSuffix(input, "!") ||
+		strings.HasSuffix(input, "?") || strings.HasSuffix(input, `"`) {
+		return input
+	}
+
+	return input + "."
+}
+
+// conjugate turns a verb into its third person singular form, keeping any
+// punctuation attached to it
+func conjugate(word string) string {
+	word = strings.ReplaceAll(word, "’", "'")
+	loc := wordRegex.FindStringIndex(word)
+	if loc == nil {
+		return word
+	}
+
+	prefix, verb, suffix := word[:loc[0]], strings.ToLower(word[loc[0]:loc[1]]), word[loc[1]:]
+
+	if irregular, ok := irregularVerbs[verb]; ok {
+		return prefix + irregular + suffix
+	}
+
+	if modalVerbs[verb] || strings.Contains(verb, "'") {
+		return prefix + verb + suffix
+	}
+
+	switch {
+	case strings.HasSuffix(verb, "s"), strings.HasSuffix(verb, "x"),
+		strings.HasSuffix(verb, "z"), strings.HasSuffix(verb, "ch"),
+		strings.HasSuffix(verb, "sh"), strings.HasSuffix(verb, "o"):
+		verb += "es"
+	case len(verb) > 1 && strings.HasSuffix(verb, "y") && !strings.ContainsAny(verb[len(verb)-2:len(verb)-1], "aeiou"):
+		verb = verb[:len(verb)-1] + "ies"
+	default:
+		verb += "s"
+	}
+
+	return prefix + verb + suffix
+}
+
+var checkRegex = regexp.MustCompile(`(?i)^(.*?)\s*\[(str|dex|con|int|wis|cha)\]\s*$`)
+
+// ParseCheck splits a skill check tag off the end of an input, ex. "pick the
+// lock [dex]" is a DEX check to "pick the lock". stat is empty if there's no
+// check.
+func ParseCheck(input string) (stat string, action string) {
+	matches := checkRegex.FindStringSubmatch(input)
+	if matches == nil {
+		return "", input
+	}
+
+	return strings.ToUpper(matches[2]), matches[1]
+}
+
+// WithOutcome adds how a skill check went to a formatted action, ex. "You
+// pick the lock." becomes "You pick the lock and succeed." attributed says
+// whether the action was attributed to a character rather than told in the
+// second person.
+func WithOutcome(action string, attributed, success bool) string {
+	action = strings.TrimRight(strings.TrimSpace(action), ".!?")
+
+	outcome := " but fail."
+	if success {
+		outcome = " and succeed."
+	}
+
+	if attributed {
+		outcome = strings.TrimSuffix(outcome, ".") + "s."
+	}
+
+	return action + outcome
+}
@@ -0,0 +1,61 @@
+package story
+
+import "testing"
+
+func TestAttribute(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"open the door", "Sir Bob opens the door."},
+		{"I open my bag", "Sir Bob opens their bag."},
+		{"don't open the door", "Sir Bob doesn't open the door."},
+		{"Don’t open the door", "Sir Bob doesn't open the door."},
+		{"can't swim", "Sir Bob can't swim."},
+		{"won't budge", "Sir Bob won't budge."},
+		{"I'll open it", "Sir Bob will open it."},
+		{"I’ll open it", "Sir Bob will open it."},
+		{"I've seen it", "Sir Bob has seen it."},
+		{"I'd run", "Sir Bob would run."},
+		{"I'm hungry", "Sir Bob is hungry."},
+		{"can swim", "Sir Bob can swim."},
+		{"should leave", "Sir Bob should leave."},
+		{"must go", "Sir Bob must go."},
+		{"Carefully open the box", "Sir Bob carefully opens the box."},
+		{"I fly away", "Sir Bob flies away."},
+		{"reply to the letter", "Sir Bob replies to the letter."},
+		{"I", "Sir Bob."},
+		{`"hello"`, `Sir Bob says "hello"`},
+	}
+
+	for _, test := range tests {
+		if got := Format(ModeDo, "Sir Bob", test.input); got != test.want {
+			t.Errorf("Format(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestSecondPerson(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"open the door", "You open the door."},
+		{"I open my bag", "You open your bag."},
+		{"I'll open it", "You'll open it."},
+		{"i'll open it", "You'll open it."},
+		{"I’ll open it", "You'll open it."},
+		{"I've seen it", "You've seen it."},
+		{"I'd run", "You'd run."},
+		{"I'm hungry", "You're hungry."},
+		{"I’M hungry", "You're hungry."},
+		{"I'm", "You're."},
+		{"I", "You."},
+	}
+
+	for _, test := range tests {
+		if got := Format(ModeDo, "", test.input); got != test.want {
+			t.Errorf("Format(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package story
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// common words that say nothing about what happened in a story
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true,
+	"had": true, "has": true, "have": true, "he": true, "her": true,
+	"his": true, "i": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "she": true, "so": true,
+	"that": true, "the": true, "their": true, "them": true, "then": true,
+	"there": true, "they": true, "this": true, "to": true, "was": true,
+	"were": true, "with": true, "you": true, "your": true,
+}
+
+var sentenceRegex = regexp.MustCompile(`[^.!?]+[.!?]+["”']?`)
+
+// Summarize picks out the sentences that best sum up the story, using how
+// often their words come up across the whole story. the opening sentence is
+// always kept, and sentences stay in the order they were told.
+func Summarize(story string, maxSentences int) string {
+	sentences := []string{}
+	end := 0
+	for _, loc := range sentenceRegex.FindAllStringIndex(story, -1) {
+		sentences = append(sentences, strings.TrimSpace(story[loc[0]:loc[1]]))
+		end = loc[1]
+	}
+
+	// outputs often trail off mid-sentence, which still counts
+	if tail := strings.TrimSpace(story[end:]); tail != "" {
+		sentences = append(sentences, tail)
+	}
+
+	if len(sentences) <= maxSentences {
+		return strings.Join(sentences, " ")
+	}
+
+	frequencies := map[string]int{}
+	for _, sentence := range sentences {
+		for _, word := range keywords(sentence) {
+			frequencies[word]++
+		}
+	}
+
+	scores := make([]float64, len(sentences))
+	for i, sentence := range sentences {
+		words := keywords(sentence)
+		if len(words) == 0 {
+			continue
+		}
+
+		for _, word := range words {
+			scores[i] += float64(frequencies[word])
+		}
+
+		scores[i] /= float64(len(words))
+	}
+
+	ranked := make([]int, len(sentences)-1)
+	for i := range ranked {
+		ranked[i] = i + 1
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+
+	picked := append([]int{0}, ranked[:maxSentences-1]...)
+	sort.Ints(picked)
+
+	summary := make([]string, len(picked))
+	for i, sentence := range picked {
+		summary[i] = sentences[sentence]
+	}
+
+	return strings.Join(summary, " ")
+}
+
+func keywords(sentence string) []string {
+	words := []string{}
+	for _, word := range wordRegex.FindAllString(strings.ToLower(sentence), -1) {
+		if !stopWords[word] && len(word) > 2 {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+	"./story"
+)
+
+// SURPRISE JOURNEYS //
+
+// when someone wants a journey but doesn't have a prompt in mind. the same
+// seed always gives the same prompt. examples:
+//
+//	<@USH186XSP> surprise me
+//	<@USH186XSP> surprise me 1234
+type SurpriseMsg struct {
+	AuthorID string
+	// 0 if not given
+	Seed int64
+	raw  *slack.MessageEvent
+}
+
+func (m SurpriseMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m SurpriseMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m SurpriseMsg) ThreadTimestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m SurpriseMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParseSurpriseMsg(m *slack.MessageEvent) (*SurpriseMsg, bool) {
+	// cannot be in a thread
+	if m.ThreadTimestamp != "" {
+		return nil, false
+	}
+
+	// cannot be in dm
+	if strings.HasPrefix(m.Channel, "D") {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:surprise me)(?: #?([0-9]+))?[.!]* *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	var seed int64
+	if matches[1] != "" {
+		var err error
+		seed, err = strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	return &SurpriseMsg{
+		AuthorID: m.User,
+		Seed:     seed,
+		raw:      m,
+	}, true
+}
+
+func (msg SurpriseMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	seed := msg.Seed
+	if seed == 0 {
+		// small enough to be easy to type back in
+		seed = time.Now().UnixNano()%1000000 + 1
+	}
+
+	prompt := story.Generate(seed)
+
+	log.Println("generated prompt from seed", seed, "-", prompt)
+
+	threadReply(rtm, msg, "_"+prompt+"_\n\n(want this one again? it's `@dungeon surprise me "+strconv.FormatInt(seed, 10)+"`)")
+
+	// from here on, it's just like someone gave us the prompt themselves
+	StartJourneyMsg{
+		AuthorID: msg.AuthorID,
+		Prompt:   prompt,
+		raw:      msg.raw,
+	}.Handle(api, rtm, dbc, aidungeonc)
+}
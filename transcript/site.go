@@ -0,0 +1,80 @@
+package transcript
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteSite generates a static storybook site in dir: an index of every
+// journey, newest first, and a page for each one
+func WriteSite(dir string, journeys []Transcript) error {
+	// thread timestamps are when the journey started
+	sort.Slice(journeys, func(i, j int) bool {
+		return journeys[i].ID > journeys[j].ID
+	})
+
+	if err := os.MkdirAll(filepath.Join(dir, "journeys"), 0755); err != nil {
+		return err
+	}
+
+	for _, journey := range journeys {
+		journey.IndexLink = "../index.html"
+
+		f, err := os.Create(filepath.Join(dir, journey.Path()))
+		if err != nil {
+			return err
+		}
+
+		if err := journey.Render(f, HTML); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return err
+	}
+
+	if err := indexTemplate.Execute(f, journeys); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Path is where the journey's page lives in the storybook, relative to its
+// index
+func (t Transcript) Path() string {
+	return "journeys/" + strings.Replace(t.ID, ".", "-", -1) + ".html"
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>The Dungeon Storybook</title>
+<style>
+body { max-width: 40em; margin: 2em auto; padding: 0 1em; font: 18px/1.6 Georgia, serif; color: #222; background: #fdfaf3; }
+li { margin-bottom: 1em; }
+.byline { color: #666; }
+</style>
+</head>
+<body>
+<h1>The Dungeon Storybook</h1>
+<p>Journeys our community has gone on, start to finish.</p>
+<ul>
+{{range .}}<li><a href="{{.Path}}">{{.Title}}</a><br><span class="byline">{{.Byline}} {{.TurnCount}} turns.{{if .Link}} <a href="{{.Link}}">Slack thread</a>{{end}}</span></li>
+{{end}}</ul>
+</body>
+</html>
+`))
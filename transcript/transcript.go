@@ -0,0 +1,244 @@
+// Rendering journeys into readable stories
+package transcript
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"../db"
+)
+
+// Formats transcripts can be rendered to
+const (
+	Markdown = "markdown"
+	HTML     = "html"
+	Text     = "text"
+)
+
+// Extension returns the file extension, without the dot, for a format
+func Extension(format string) string {
+	switch format {
+	case Markdown:
+		return "md"
+	case HTML:
+		return "html"
+	}
+
+	return "txt"
+}
+
+type Transcript struct {
+	// the journey's thread timestamp
+	ID         string
+	Title      string
+	Creator    db.SlackUser
+	Companions []db.SlackUser
+	Prompt     string
+	Turns      []db.Turn
+	Epilogue   string
+	// permalink to the journey's slack thread, if known
+	Link string
+	// link back to the storybook's index, for published transcripts
+	IndexLink string
+
+	characters []db.SlackUser
+}
+
+// New builds a transcript of a session from its story items
+func New(session db.Session, items []db.StoryItem) Transcript {
+	t := Transcript{
+		ID:         session.ThreadTimestamp,
+		Title:      Title(session.Prompt),
+		Creator:    session.Creator,
+		Companions: session.Companions,
+		Prompt:     session.Prompt,
+		Turns:      db.StoryTurns(items),
+		characters: session.Characters,
+	}
+
+	for _, item := range items {
+		if item.Type == "Epilogue" {
+			t.Epilogue = item.Value
+		}
+	}
+
+	return t
+}
+
+// Title makes a short title out of a journey's prompt
+func Title(prompt string) string {
+	title := strings.TrimSpace(prompt)
+	if i := strings.IndexAny(title, ".!?\n"); i != -1 {
+		title = title[:i]
+	}
+
+	words := strings.Fields(title)
+	if len(words) > 10 {
+		return strings.Join(words[:10], " ") + "..."
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Speaker describes who an input came from, ex. "Zach (as Sir Bob)"
+func (t Transcript) Speaker(input db.StoryItem) string {
+	if input.Author == nil {
+		return "Someone"
+	}
+
+	speaker := Name(*input.Author)
+	for _, character := range t.characters {
+		if character.Eq(*input.Author) {
+			speaker += " (as " + character.Name + ")"
+		}
+	}
+
+	return speaker
+}
+
+// Name returns the best name we have for a slack user
+func Name(user db.SlackUser) string {
+	if user.Name != "" {
+		return user.Name
+	}
+
+	return user.ID
+}
+
+// TurnCount is how many turns players took. the journey's opening isn't one.
+func (t Transcript) TurnCount() int {
+	count := 0
+	for _, turn := range t.Turns {
+		count += len(turn.Inputs)
+	}
+
+	return count
+}
+
+// Byline credits everyone who went on the journey
+func (t Transcript) Byline() string {
+	byline := "A journey by " + Name(t.Creator)
+	for i, companion := range t.Companions {
+		if i == 0 {
+			byline += ", with "
+		} else if i == len(t.Companions)-1 {
+			byline += " and "
+		} else {
+			byline += ", "
+		}
+
+		byline += Name(companion)
+	}
+
+	return byline + "."
+}
+
+// Render writes the transcript in the given format
+func (t Transcript) Render(w io.Writer, format string) error {
+	switch format {
+	case Markdown:
+		return t.renderMarkdown(w)
+	case HTML:
+		return htmlTemplate.Execute(w, t)
+	case Text:
+		return t.renderText(w)
+	}
+
+	return errors.New("unknown transcript format: " + format)
+}
+
+func (t Transcript) renderMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", t.Title)
+	fmt.Fprintf(&b, "_%s_\n\n", t.Byline())
+	if t.Link != "" {
+		fmt.Fprintf(&b, "[Read it on Slack](%s)\n\n", t.Link)
+	}
+
+	fmt.Fprintf(&b, "## Prompt\n\n> %s\n\n## Story\n\n", strings.ReplaceAll(strings.TrimSpace(t.Prompt), "\n", "\n> "))
+
+	for _, turn := range t.Turns {
+		for _, input := range turn.Inputs {
+			fmt.Fprintf(&b, "**%s:** _%s_\n\n", t.Speaker(input), strings.TrimSpace(input.Value))
+		}
+
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(turn.Output.Value))
+	}
+
+	if t.Epilogue != "" {
+		fmt.Fprintf(&b, "## Epilogue\n\n%s\n\n", strings.TrimSpace(t.Epilogue))
+	}
+
+	fmt.Fprint(&b, "_~ The End ~_\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (t Transcript) renderText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n%s\n\n", t.Title, strings.Repeat("=", len(t.Title)))
+	fmt.Fprintf(&b, "%s\n", t.Byline())
+	if t.Link != "" {
+		fmt.Fprintf(&b, "%s\n", t.Link)
+	}
+
+	fmt.Fprintf(&b, "\nPROMPT\n\n%s\n\nSTORY\n\n", strings.TrimSpace(t.Prompt))
+
+	for _, turn := range t.Turns {
+		for _, input := range turn.Inputs {
+			fmt.Fprintf(&b, "> %s: %s\n\n", t.Speaker(input), strings.TrimSpace(input.Value))
+		}
+
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(turn.Output.Value))
+	}
+
+	if t.Epilogue != "" {
+		fmt.Fprintf(&b, "EPILOGUE\n\n%s\n\n", strings.TrimSpace(t.Epilogue))
+	}
+
+	fmt.Fprint(&b, "~ The End ~\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"paragraphs": func(text string) []string {
+		return strings.Split(strings.TrimSpace(text), "\n\n")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 40em; margin: 2em auto; padding: 0 1em; font: 18px/1.6 Georgia, serif; color: #222; background: #fdfaf3; }
+h1 { line-height: 1.2; }
+.byline, .input { color: #666; }
+.input { font-style: italic; border-left: 3px solid #ccc; padding-left: 1em; }
+blockquote { margin: 0; padding-left: 1em; border-left: 3px solid #e0d6bd; }
+footer { margin-top: 3em; text-align: center; font-style: italic; }
+</style>
+</head>
+<body>
+{{if .IndexLink}}<nav><a href="{{.IndexLink}}">&larr; All journeys</a></nav>
+{{end}}<h1>{{.Title}}</h1>
+<p class="byline">{{.Byline}}{{if .Link}} <a href="{{.Link}}">Read it on Slack</a>.{{end}}</p>
+<h2>Prompt</h2>
+<blockquote>{{range paragraphs .Prompt}}<p>{{.}}</p>{{end}}</blockquote>
+<h2>Story</h2>
+{{range .Turns}}{{range .Inputs}}<p class="input"><strong>{{$.Speaker .}}:</strong> {{.Value}}</p>
+{{end}}{{range paragraphs .Output.Value}}<p>{{.}}</p>
+{{end}}{{end}}{{if .Epilogue}}<h2>Epilogue</h2>
+{{range paragraphs .Epilogue}}<p>{{.}}</p>
+{{end}}{{end}}<footer>~ The End ~</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// TURN TAKING //
+
+// when the creator changes how inputs are accepted on their journey. examples:
+//
+//	<@USH186XSP> mode turns
+//	<@USH186XSP> mode vote
+//	<@USH186XSP> mode free
+type PlayModeMsg struct {
+	AuthorID string
+	Mode     string
+	raw      *slack.MessageEvent
+}
+
+func (m PlayModeMsg) ChannelID() string {
+	return m.raw.Channel
+}
+
+func (m PlayModeMsg) Timestamp() string {
+	return m.raw.Timestamp
+}
+
+func (m PlayModeMsg) ThreadTimestamp() string {
+	return m.raw.ThreadTimestamp
+}
+
+func (m PlayModeMsg) Raw() *slack.MessageEvent {
+	return m.raw
+}
+
+func ParsePlayModeMsg(m *slack.MessageEvent) (*PlayModeMsg, bool) {
+	// must be in a thread
+	if m.ThreadTimestamp == "" {
+		return nil, false
+	}
+
+	regex := regexp.MustCompile(`^<@` + SelfID + `> (?i:mode (turns|vote|free)) *$`)
+	matches := regex.FindStringSubmatch(m.Text)
+	if matches == nil {
+		return nil, false
+	}
+
+	var mode string
+	switch strings.ToLower(matches[1]) {
+	case "turns":
+		mode = db.PlayModeTurns
+	case "vote":
+		mode = db.PlayModeVote
+	case "free":
+		mode = db.PlayModeFree
+	}
+
+	return &PlayModeMsg{
+		AuthorID: m.User,
+		Mode:     mode,
+		raw:      m,
+	}, true
+}
+
+func (msg PlayModeMsg) Handle(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	log.Println("play mode change requested:", msg)
+
+	session, err := dbc.GetSession(msg.ThreadTimestamp())
+	if err != nil {
+		log.Println("play mode change attempted, unable to find session:", err, "-", msg)
+		threadReply(rtm, msg, "...I'm sorry. What are you talking about? We're not on a journey together right now.")
+		return
+	}
+
+	author, err := db.SlackUserFromID(api, msg.AuthorID)
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+
+	if !session.Creator.Eq(author) {
+		threadReply(rtm, msg, "Only "+session.Creator.Name+" can change how we play this journey.")
+		return
+	}
+
+	if session.PlayMode == msg.Mode {
+		threadReply(rtm, msg, "We're already playing that way!")
+		return
+	}
+
+	if msg.Mode == db.PlayModeTurns && len(session.Companions) == 0 {
+		threadReply(rtm, msg, "Taking turns with yourself? Invite some companions first with `@dungeon invite @someone`.")
+		return
+	}
+
+	session, err = dbc.SetSessionPlayMode(session, msg.Mode, session.Participants(), 0)
+	if err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if err := dbc.CreateStoryItem(session, "Metadata", &author, "switched play mode to "+msg.Mode); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if msg.Mode == db.PlayModeFree {
+		threadReply(rtm, msg, "No more turns! Anyone on the journey can jump in whenever they like.")
+		return
+	}
+
+	if msg.Mode == db.PlayModeVote {
+		threadReply(rtm, msg, "Democracy it is! Propose what we do next with `@dungeon <your action>`. After "+
+			config().VoteProposalWindow.String()+" I'll post a ballot, and everyone gets "+config().VoteWindow.String()+" to vote with reactions.")
+		return
+	}
+
+	threadReply(rtm, msg, "Alright, we'll take turns in this order: "+mentions(session.TurnOrder)+
+		". If you don't act within "+config().TurnTimeout.String()+", I'll move on without you.")
+	announceTurn(rtm, msg, session)
+}
+
+func announceTurn(rtm *slack.RTM, thread Thread, session db.Session) {
+	player, ok := session.CurrentPlayer()
+	if !ok {
+		return
+	}
+
+	threadReply(rtm, thread, "_<@"+player.ID+">, you're up!_")
+}
+
+// syncTurnOrder keeps a turn-taking session's turn order in line with its
+// participants after people join or leave, keeping the turn with the current
+// player if they're still around.
+func syncTurnOrder(dbc *db.DB, session db.Session) (db.Session, error) {
+	if session.PlayMode != db.PlayModeTurns {
+		return session, nil
+	}
+
+	turnOrder := session.Participants()
+	currentTurn := session.CurrentTurn % len(turnOrder)
+	if currentPlayer, ok := session.CurrentPlayer(); ok {
+		for i, player := range turnOrder {
+			if player.Eq(currentPlayer) {
+				currentTurn = i
+			}
+		}
+	}
+
+	return dbc.SetSessionPlayMode(session, db.PlayModeTurns, turnOrder, currentTurn)
+}
+
+// skipIdleTurns moves turn-taking journeys along when the current player
+// hasn't acted within the configured timeout.
+func skipIdleTurns(rtm *slack.RTM, dbc *db.DB) {
+	sessions, err := dbc.ListSessionsInPlayMode(db.PlayModeTurns)
+	if err != nil {
+		log.Println("unable to list turn-taking sessions:", err)
+		return
+	}
+
+	for _, session := range sessions {
+		// sessions from before we tracked channels can't be replied to
+		if session.ChannelID == "" {
+			continue
+		}
+
+		skipped, ok := session.CurrentPlayer()
+		if !ok || session.TurnStartedAt.Add(config().TurnTimeout).After(time.Now()) {
+			continue
+		}
+
+		log.Println("skipping idle turn of", skipped.ToString(), "in session", session.ThreadTimestamp)
+
+		session, err = dbc.AdvanceSessionTurn(session)
+		if err != nil {
+			log.Println("unable to advance turn:", err)
+			continue
+		}
+
+		if err := dbc.CreateStoryItem(session, "Metadata", nil, "skipped idle turn of "+skipped.ToString()); err != nil {
+			log.Println("unable to record skipped turn:", err)
+		}
+
+		thread := sessionThread{session}
+		threadReply(rtm, thread, "_<@"+skipped.ID+"> seems to have wandered off..._")
+		announceTurn(rtm, thread, session)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// VOTING //
+
+// reactions players vote with, in ballot order. also caps how many actions
+// can be proposed per round.
+var ballotEmoji = []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "keycap_ten"}
+
+// proposeAction records a participant's proposed action for the current
+// voting round, opening a new round if one isn't already running.
+func proposeAction(api *slack.Client, rtm *slack.RTM, msg Msg, dbc *db.DB, session db.Session, author db.SlackUser, action string) {
+	if session.VotePhase == db.VotePhaseVoting {
+		threadReply(rtm, msg, "Voting's already underway! Cast your vote on the ballot above and you can propose something new next round.")
+		return
+	}
+
+	proposals := []db.StoryItem{}
+	if session.VotePhase == db.VotePhaseProposing {
+		items, err := dbc.GetStoryItems(session)
+		if err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		proposals = currentProposals(items)
+	}
+
+	for _, proposal := range proposals {
+		if proposal.Author != nil && proposal.Author.Eq(author) {
+			threadReply(rtm, msg, "You've already thrown your idea in the hat this round!")
+			return
+		}
+	}
+
+	if len(proposals) >= len(ballotEmoji) {
+		threadReply(rtm, msg, "The ballot's full this round! Sit tight and vote when it goes up.")
+		return
+	}
+
+	if err := dbc.CreateStoryItem(session, "Proposal", &author, action); err != nil {
+		handleDBError(rtm, msg, err)
+		return
+	}
+
+	if session.VotePhase == db.VotePhaseNone {
+		if _, err := dbc.SetSessionVotePhase(session, db.VotePhaseProposing, ""); err != nil {
+			handleDBError(rtm, msg, err)
+			return
+		}
+
+		threadReply(rtm, msg, "A new round begins! Propose what we do next with `@dungeon <your action>` for the next "+
+			config().VoteProposalWindow.String()+", then we'll put it to a vote.")
+	}
+
+	err := api.AddReaction("ballot_box_with_ballot", slack.ItemRef{
+		Channel:   msg.ChannelID(),
+		Timestamp: msg.Timestamp(),
+	})
+	if err != nil {
+		handleSlackError(rtm, msg, err)
+		return
+	}
+}
+
+// currentProposals returns the proposals made since the last round was
+// settled, in the order they were made
+func currentProposals(items []db.StoryItem) []db.StoryItem {
+	proposals := []db.StoryItem{}
+	for _, item := range items {
+		switch item.Type {
+		case "Proposal":
+			proposals = append(proposals, item)
+		case "Vote Result":
+			proposals = []db.StoryItem{}
+		}
+	}
+
+	return proposals
+}
+
+// runVoteRounds moves voting journeys through each round once its proposal
+// or voting window has closed.
+func runVoteRounds(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	sessions, err := dbc.ListSessionsInPlayMode(db.PlayModeVote)
+	if err != nil {
+		log.Println("unable to list voting sessions:", err)
+		return
+	}
+
+	for _, session := range sessions {
+		// sessions from before we tracked channels can't be replied to
+		if session.ChannelID == "" {
+			continue
+		}
+
+		switch session.VotePhase {
+		case db.VotePhaseProposing:
+			if time.Since(session.VoteStartedAt) >= config().VoteProposalWindow {
+				postBallot(api, rtm, dbc, session)
+			}
+		case db.VotePhaseVoting:
+			if time.Since(session.VoteStartedAt) >= config().VoteWindow {
+				settleVote(api, rtm, dbc, aidungeonc, session)
+			}
+		}
+	}
+}
+
+func postBallot(api *slack.Client, rtm *slack.RTM, dbc *db.DB, session db.Session) {
+	thread := sessionThread{session}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		log.Println("unable to get proposals:", err)
+		return
+	}
+
+	proposals := currentProposals(items)
+
+	ballot := "*Time to vote!* React with the number of the action you want us to take. The ballot closes in " +
+		config().VoteWindow.String() + ".\n"
+	for i, proposal := range proposals {
+		ballot += "\n:" + ballotEmoji[i] + ": " + proposal.Value
+		if proposal.Author != nil {
+			ballot += " _(" + proposal.Author.Name + ")_"
+		}
+	}
+
+	ballotTs, err := threadPost(api, thread, ballot)
+	if err != nil {
+		handleSlackError(rtm, thread, err)
+		return
+	}
+
+	for i := range proposals {
+		err := api.AddReaction(ballotEmoji[i], slack.ItemRef{
+			Channel:   session.ChannelID,
+			Timestamp: ballotTs,
+		})
+		if err != nil {
+			handleSlackError(rtm, thread, err)
+			return
+		}
+	}
+
+	if _, err := dbc.SetSessionVotePhase(session, db.VotePhaseVoting, ballotTs); err != nil {
+		handleDBError(rtm, thread, err)
+		return
+	}
+}
+
+func settleVote(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client, session db.Session) {
+	thread := sessionThread{session}
+
+	items, err := dbc.GetStoryItems(session)
+	if err != nil {
+		log.Println("unable to get proposals:", err)
+		return
+	}
+
+	proposals := currentProposals(items)
+
+	reactions, err := api.GetReactions(slack.ItemRef{
+		Channel:   session.ChannelID,
+		Timestamp: session.BallotTimestamp,
+	}, slack.GetReactionsParameters{Full: true})
+	if err != nil {
+		handleSlackError(rtm, thread, err)
+		return
+	}
+
+	// anyone can vote, even if they can't propose. you can vote for as
+	// many actions as you like, but only once for each.
+	votes := make([]int, len(proposals))
+	voters := map[string]bool{}
+	for _, reaction := range reactions {
+		for i := range proposals {
+			if reaction.Name != ballotEmoji[i] {
+				continue
+			}
+
+			for _, user := range reaction.Users {
+				if user == SelfID {
+					continue
+				}
+
+				votes[i]++
+				voters[user] = true
+			}
+		}
+	}
+
+	tally := ""
+	for i, proposal := range proposals {
+		tally += "\n• " + proposal.Value + " — " + strconv.Itoa(votes[i]) + " vote(s)"
+	}
+
+	var winner *db.StoryItem
+	var result string
+
+	leaders := []int{}
+	for i := range proposals {
+		if len(leaders) == 0 || votes[i] > votes[leaders[0]] {
+			leaders = []int{i}
+		} else if votes[i] == votes[leaders[0]] {
+			leaders = append(leaders, i)
+		}
+	}
+
+	switch {
+	case len(proposals) == 0:
+		result = "no proposals"
+	case len(voters) < config().VoteMinTurnout:
+		result = "not enough votes (" + strconv.Itoa(len(voters)) + " of " + strconv.Itoa(config().VoteMinTurnout) + " needed)"
+	case len(leaders) > 1 && config().VoteTieBreak == "none":
+		result = "tied with no tie-breaker"
+	case len(leaders) > 1 && config().VoteTieBreak == "random":
+		winner = &proposals[leaders[rand.Intn(len(leaders))]]
+		result = "won a tie-breaking coin flip: " + winner.Value
+	default:
+		// ties go to whoever proposed first
+		winner = &proposals[leaders[0]]
+		result = "won: " + winner.Value
+	}
+
+	if err := dbc.CreateStoryItem(session, "Vote Result", nil, result+tally); err != nil {
+		handleDBError(rtm, thread, err)
+		return
+	}
+
+	session, err = dbc.SetSessionVotePhase(session, db.VotePhaseNone, "")
+	if err != nil {
+		handleDBError(rtm, thread, err)
+		return
+	}
+
+	if winner == nil {
+		threadReply(rtm, thread, "The votes are in... and we have no winner ("+result+"). Propose something new to start another round!")
+		return
+	}
+
+	threadReply(rtm, thread, "The votes are in! *"+strings.TrimSpace(winner.Value)+"*"+tally)
+
+	tellStory(api, rtm, thread, dbc, aidungeonc, session, *winner.Author, winner.Value)
+}
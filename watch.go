@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"./aidungeon"
+	"./db"
+)
+
+// BACKGROUND JOBS //
+
+// how often background jobs check on sessions
+const watchInterval = time.Minute
+
+// Lets background jobs reply in a session's thread
+type sessionThread struct {
+	session db.Session
+}
+
+func (t sessionThread) ChannelID() string {
+	return t.session.ChannelID
+}
+
+func (t sessionThread) ThreadTimestamp() string {
+	return t.session.ThreadTimestamp
+}
+
+// watchSessions periodically runs jobs that act on sessions without anyone
+// messaging us. All state lives in the store, so jobs pick up where they left
+// off across restarts.
+func watchSessions(api *slack.Client, rtm *slack.RTM, dbc *db.DB, aidungeonc aidungeon.Client) {
+	for range time.Tick(watchInterval) {
+		skipIdleTurns(rtm, dbc)
+		runVoteRounds(api, rtm, dbc, aidungeonc)
+		pauseIdleSessions(rtm, dbc)
+		announceWeeklyQuest(rtm, dbc)
+		runDailyAdventure(api, rtm, dbc, aidungeonc)
+		postWeeklyDigest(api, dbc)
+		refreshScenarioNames(dbc)
+	}
+}